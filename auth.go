@@ -0,0 +1,55 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// dbAuthPrefix begins the pseudo-collections in which
+// `mongodump --dumpDbUsersAndRoles` archives a database’s users & roles,
+// e.g., mydb.$admin.system.users.
+const dbAuthPrefix = "$admin."
+
+// DatabaseAuth says whether an archive holds a database’s users and
+// roles.
+type DatabaseAuth struct {
+	DB    string `bson:"db"`
+	Users bool   `bson:"users"`
+	Roles bool   `bson:"roles"`
+}
+
+// getDatabaseAuth finds the databases whose users or roles the archive
+// holds, ordered by name. These are admin itself, any database dumped
+// with --dumpDbUsersAndRoles, and any database with its own system.users
+// (as servers before 2.6 kept).
+func getDatabaseAuth(mdDocs []bson.D) []DatabaseAuth {
+	var dbAuth []DatabaseAuth
+
+	for _, mdDoc := range mdDocs {
+		db, coll := getNamespace(mdDoc)
+		coll = strings.TrimPrefix(coll, dbAuthPrefix)
+
+		isUsers, isRoles := coll == "system.users", coll == "system.roles"
+		if !isUsers && !isRoles {
+			continue
+		}
+
+		i := slices.IndexFunc(dbAuth, func(auth DatabaseAuth) bool { return auth.DB == db })
+		if i < 0 {
+			i = len(dbAuth)
+			dbAuth = append(dbAuth, DatabaseAuth{DB: db})
+		}
+
+		dbAuth[i].Users = dbAuth[i].Users || isUsers
+		dbAuth[i].Roles = dbAuth[i].Roles || isRoles
+	}
+
+	slices.SortFunc(dbAuth, func(a, b DatabaseAuth) int {
+		return cmp.Compare(a.DB, b.DB)
+	})
+
+	return dbAuth
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDatabaseAuth(t *testing.T) {
+	mdDocs := []bson.D{
+		makeMetadataDoc("sales", "orders"),
+		makeMetadataDoc("sales", "$admin.system.users"),
+		makeMetadataDoc("sales", "$admin.system.roles"),
+		makeMetadataDoc("admin", "system.users"),
+		makeMetadataDoc("legacy", "system.users"),
+		makeMetadataDoc("hr", "$admin.system.roles"),
+		makeMetadataDoc("hr", "$admin.system.version"),
+	}
+
+	assert.Equal(
+		t,
+		[]DatabaseAuth{
+			{DB: "admin", Users: true},
+			{DB: "hr", Roles: true},
+			{DB: "legacy", Users: true},
+			{DB: "sales", Users: true, Roles: true},
+		},
+		getDatabaseAuth(mdDocs),
+		"should find each database’s users & roles",
+	)
+
+	assert.Nil(t, getDatabaseAuth(mdDocs[:1]), "should find nothing without auth data")
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// These are the values of Report.BlockCompression.
+const (
+	blockCompressionNone  = "none"
+	blockCompressionGzip  = "gzip"
+	blockCompressionMixed = "mixed"
+)
+
+// gzipBlockStart is how a gzip-compressed body block begins: gzip’s magic
+// bytes, its deflate method, and no flags. (A document of exactly 559,903
+// bytes begins the same way, but such a document is improbable.)
+var gzipBlockStart = []byte{0x1f, 0x8b, 0x08, 0x00}
+
+// openBlock returns a reader for a body block’s documents, which end with
+// a terminator. mongodump never compresses individual blocks, but a buggy
+// or future version might; if a block is gzip-compressed, this reads it
+// through a gzip reader, which it also returns so that closeBlock can
+// check the block’s end.
+func openBlock(cr *countingReader) (*countingReader, *gzip.Reader, error) {
+	start, err := cr.Peek(len(gzipBlockStart))
+	if err != nil || !bytes.Equal(start, gzipBlockStart) {
+		// Let the document loop report short input.
+		return cr, nil, nil
+	}
+
+	gzipReader, err := gzip.NewReader(cr)
+	if err != nil {
+		return nil, nil, markIfTruncated(errors.Wrap(err, "failed to read compressed block’s gzip header"))
+	}
+
+	// Each compressed block is its own gzip stream.
+	gzipReader.Multistream(false)
+
+	return newCountingReader(bufio.NewReader(gzipReader)), gzipReader, nil
+}
+
+// closeBlock reads the rest of a compressed block’s gzip stream, which
+// should be empty. This also verifies the stream’s checksum.
+func closeBlock(blockReader *countingReader, gzipReader *gzip.Reader) error {
+	if gzipReader == nil {
+		return nil
+	}
+
+	// (bufio.Reader’s WriteTo would bypass the count.)
+	extra, err := io.Copy(io.Discard, struct{ io.Reader }{blockReader})
+	if err != nil {
+		return markIfTruncated(errors.Wrap(err, "failed to finish compressed block"))
+	}
+
+	if extra > 0 {
+		return markError(errors.Errorf("compressed block has %d bytes after its terminator", extra), ErrCorrupt)
+	}
+
+	return nil
+}
+
+// mergeBlockCompression combines a namespace’s compression so far (or
+// "" if none) with that of another of its blocks.
+func mergeBlockCompression(prev string, compressed bool) string {
+	compression := blockCompressionNone
+	if compressed {
+		compression = blockCompressionGzip
+	}
+
+	if prev == "" || prev == compression {
+		return compression
+	}
+
+	return blockCompressionMixed
+}
+
+// isCompressionMixed indicates whether namespaces’ blocks aren’t all
+// compressed alike.
+func isCompressionMixed(compression map[string]string) bool {
+	seen := ""
+
+	for _, c := range compression {
+		if c == blockCompressionMixed || (seen != "" && c != seen) {
+			return true
+		}
+
+		seen = c
+	}
+
+	return false
+}
+
+// hasCompressedBlocks indicates whether any namespace has a compressed
+// block.
+func hasCompressedBlocks(compression map[string]string) bool {
+	for _, c := range compression {
+		if c != blockCompressionNone {
+			return true
+		}
+	}
+
+	return false
+}
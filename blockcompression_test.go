@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBlockCompression(t *testing.T) {
+	doc := bson.D{{Key: "_id", Value: int32(1)}}
+
+	dump := makeArchive(
+		t,
+		bson.D{},
+		[]bson.D{makeMetadataDoc("db", "plain"), makeMetadataDoc("db", "zipped"), makeMetadataDoc("db", "mixed")},
+		[]testBlock{
+			{db: "db", coll: "plain", docs: []bson.D{doc, doc}},
+			{db: "db", coll: "zipped", docs: []bson.D{doc, doc, doc}, gzip: true},
+			{db: "db", coll: "mixed", docs: []bson.D{doc}, gzip: true},
+			{db: "db", coll: "mixed", docs: []bson.D{doc}},
+			{db: "db", coll: "plain", eof: true},
+			{db: "db", coll: "zipped", eof: true},
+			{db: "db", coll: "mixed", eof: true},
+		},
+	)
+
+	warnings := bytes.Buffer{}
+	report, err := getReport(
+		bytes.NewReader(dump),
+		&warnings,
+		ParseOptions{CountDocuments: true, VerifyCRC: true, SampleSize: 1},
+	)
+	require.NoError(t, err, "should parse archive")
+
+	assert.Equal(
+		t,
+		map[string]int64{"db.plain": 2, "db.zipped": 3, "db.mixed": 2},
+		report.DocumentCounts,
+		"should count documents in compressed blocks",
+	)
+	assert.Equal(
+		t,
+		map[string]string{"db.plain": "none", "db.zipped": "gzip", "db.mixed": "mixed"},
+		report.BlockCompression,
+		"should report each namespace’s compression",
+	)
+	assert.Equal(t, []bson.D{doc}, report.Samples["db.zipped"], "should decode compressed documents")
+	assert.EqualValues(t, len(dump), report.BytesRead, "should count compressed bytes")
+	assert.Contains(t, warnings.String(), "mixes gzip-compressed and uncompressed", "should warn")
+
+	report, err = getReport(bytes.NewReader(makeArchive(
+		t,
+		bson.D{},
+		[]bson.D{makeMetadataDoc("db", "plain")},
+		[]testBlock{{db: "db", coll: "plain", docs: []bson.D{doc}}, {db: "db", coll: "plain", eof: true}},
+	)), &warnings, ParseOptions{CountDocuments: true})
+	require.NoError(t, err, "should parse archive")
+	assert.Nil(t, report.BlockCompression, "should omit compression when no block is compressed")
+
+	truncated := dump[:len(dump)-60]
+	_, err = getReport(bytes.NewReader(truncated), &warnings, ParseOptions{CountDocuments: true})
+	assert.Error(t, err, "should fail on a truncated archive")
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc64"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/mongodb/mongo-tools/common/archive"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// The server accepts documents slightly larger than 16 MiB internally,
+// so we allow the same slack.
+const defaultMaxDocumentSize = 16*1024*1024 + 16*1024
+
+// minDocumentSize is the size of an empty BSON document.
+const minDocumentSize = 5
+
+func validateMaxDocumentSize(size int64) error {
+	if size < minDocumentSize {
+		return fmt.Errorf("maximum document size must be at least %d, not %d", minDocumentSize, size)
+	}
+
+	return nil
+}
+
+func validateDocumentLimit(limit int64) error {
+	if limit < 0 {
+		return fmt.Errorf("document limit must be non-negative, not %d", limit)
+	}
+
+	return nil
+}
+
+// documentAnalyzer examines decoded body documents.
+type documentAnalyzer interface {
+	// docLimit is how many of the namespace’s documents the analyzer
+	// needs to see.
+	docLimit(header archive.NamespaceHeader) int
+
+	// analyze examines one of the namespace’s documents. The analyzer
+	// must not modify doc.
+	analyze(header archive.NamespaceHeader, doc bson.D)
+}
+
+// rawDocumentAnalyzer is a documentAnalyzer that examines documents’ raw
+// BSON instead, so readBody needn’t decode documents just for it.
+type rawDocumentAnalyzer interface {
+	documentAnalyzer
+
+	// analyzeRaw examines one of the namespace’s documents in place of
+	// analyze. The analyzer must not modify or retain raw. An error stops
+	// readBody.
+	analyzeRaw(header archive.NamespaceHeader, raw bson.Raw) error
+}
+
+// documentHandler passes the documents of the namespaces that matches
+// accepts to ParseOptions.DocumentHandler.
+type documentHandler struct {
+	handle  func(ns string, raw bson.Raw) error
+	matches func(ns string) bool
+}
+
+func (dh documentHandler) docLimit(header archive.NamespaceHeader) int {
+	if dh.handle == nil || !dh.matches(header.Database+"."+header.Collection) {
+		return 0
+	}
+
+	return math.MaxInt
+}
+
+// analyze is unused, since readBody prefers analyzeRaw.
+func (dh documentHandler) analyze(archive.NamespaceHeader, bson.D) {}
+
+func (dh documentHandler) analyzeRaw(header archive.NamespaceHeader, raw bson.Raw) error {
+	return dh.handle(header.Database+"."+header.Collection, raw)
+}
+
+// bodyStats is what readBody learns about each namespace’s documents.
+type bodyStats struct {
+	docCounts map[string]int64
+	docBytes  map[string]int64
+
+	// maxOpenNamespaces is the most namespaces that had blocks but had
+	// not yet reached EOF at any one time.
+	maxOpenNamespaces int
+
+	// ended holds the namespaces whose EOF header the body contains.
+	ended map[string]bool
+
+	// blocks counts each namespace’s data blocks, excluding EOF.
+	blocks map[string]int
+
+	// compression is each namespace’s block compression: “none”,
+	// “gzip”, or “mixed”. Namespaces without data blocks are absent.
+	compression map[string]string
+
+	// trailing describes any data after the last namespace’s EOF that
+	// isn’t a namespace header.
+	trailing *TrailingBytes
+}
+
+// countNamespaceDocuments counts one namespace’s documents without
+// decoding any documents. Of opts, it honors only the options that affect
+// how the archive is read, not what is reported.
+func countNamespaceDocuments(
+	input io.Reader,
+	errOut io.Writer,
+	ns string,
+	opts ParseOptions,
+) (int64, error) {
+	db, coll, ok := strings.Cut(ns, ".")
+	if !ok {
+		return 0, fmt.Errorf("namespace must be of the form “db.collection”, not %#q", ns)
+	}
+
+	report, err := getReport(input, errOut, ParseOptions{
+		CountDocuments:  true,
+		MaxDocumentSize: opts.MaxDocumentSize,
+		ProgressOut:     opts.ProgressOut,
+		ProgressTotal:   opts.ProgressTotal,
+		MagicNumber:     opts.MagicNumber,
+		SkipBytes:       opts.SkipBytes,
+		Follow:          opts.Follow,
+		DBs:             []string{db},
+		Collections:     []string{coll},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(report.CollectionMetadata) == 0 {
+		return 0, fmt.Errorf("archive does not contain %#q", ns)
+	}
+
+	return report.DocumentCounts[ns], nil
+}
+
+// readBody reads the archive body, i.e., everything after the collection
+// metadata, whose documents are mdDocs. Documents are skipped rather than
+// read into memory unless an analyzer needs them.
+func readBody(
+	cr *countingReader,
+	opts ParseOptions,
+	mdDocs []bson.D,
+	analyzers []documentAnalyzer,
+) (bodyStats, error) {
+	counts := map[string]int64{}
+	sizes := map[string]int64{}
+	crcs := map[string]hash.Hash64{}
+	open := map[string]bool{}
+	ended := map[string]bool{}
+	blocks := map[string]int{}
+	compression := map[string]string{}
+	maxOpen := 0
+	scratch := bsonBuffer{}
+
+	var trailing *TrailingBytes
+
+	for {
+		// A followed input reports EOF only after a long idle timeout,
+		// so we stop once the body is complete rather than wait for it.
+		if opts.Follow && len(open) == 0 &&
+			len(getUnendedNamespaces(mdDocs, bodyStats{docCounts: counts, ended: ended})) == 0 {
+			break
+		}
+
+		_, err := cr.Peek(1)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return bodyStats{}, errors.Wrap(err, "failed to check for end of archive")
+		}
+
+		// Once every namespace has ended, the archive may validly end.
+		// Anything that follows but isn’t a namespace header, then, is
+		// extraneous rather than corrupt.
+		if len(open) == 0 && len(ended) > 0 && !isNamespaceHeaderNext(cr, opts.maxDocumentSize()) {
+			trailing, err = readTrailingBytes(cr)
+			if err != nil {
+				return bodyStats{}, err
+			}
+
+			cr.explain(trailing.Offset, "found %d trailing bytes", trailing.Length)
+
+			break
+		}
+
+		offset := cr.BytesRead()
+
+		header := archive.NamespaceHeader{}
+		err = readBSONBuffered(cr, &scratch, &header)
+		if err != nil {
+			return bodyStats{}, errors.Wrap(err, "failed to read namespace header")
+		}
+
+		ns := header.Database + "." + header.Collection
+		prevCount := counts[ns]
+		counts[ns] += 0
+		sizes[ns] += 0
+		blocks[ns] += 0
+
+		if header.EOF {
+			delete(open, ns)
+			ended[ns] = true
+		} else {
+			blocks[ns]++
+			open[ns] = true
+			maxOpen = max(maxOpen, len(open))
+		}
+
+		var crc hash.Hash64
+		if opts.VerifyCRC {
+			if crcs[ns] == nil {
+				crcs[ns] = crc64.New(crc64.MakeTable(crc64.ECMA))
+			}
+			crc = crcs[ns]
+
+			if header.EOF && int64(crc.Sum64()) != header.CRC {
+				return bodyStats{}, markError(
+					fmt.Errorf("%#q’s CRC (%d) does not match archive’s (%d)", ns, int64(crc.Sum64()), header.CRC),
+					ErrCRCMismatch,
+				)
+			}
+		}
+
+		// We read documents that any analyzer needs but decode only those
+		// that an analyzer of decoded documents needs.
+		limits := make([]int64, len(analyzers))
+		docLimit, decodeLimit := int64(0), int64(0)
+		for i, analyzer := range analyzers {
+			limits[i] = int64(analyzer.docLimit(header))
+			docLimit = max(docLimit, limits[i])
+
+			if _, ok := analyzer.(rawDocumentAnalyzer); !ok {
+				decodeLimit = max(decodeLimit, limits[i])
+			}
+		}
+
+		blockReader, gzipReader := cr, (*gzip.Reader)(nil)
+		if !header.EOF {
+			blockReader, gzipReader, err = openBlock(cr)
+			if err != nil {
+				return bodyStats{}, errors.Wrapf(err, "failed to read %#q block", ns)
+			}
+
+			compression[ns] = mergeBlockCompression(compression[ns], gzipReader != nil)
+		}
+
+		for {
+			docLen, err := peekDocumentLength(blockReader, opts.maxDocumentSize())
+			if err != nil {
+				return bodyStats{}, errors.Wrapf(err, "failed to read %#q document", ns)
+			}
+
+			if docLen == 0 {
+				break
+			}
+
+			switch {
+			case counts[ns] < docLimit:
+				raw, err := bson.ReadDocument(blockReader)
+				if err != nil {
+					return bodyStats{}, markIfTruncated(
+						errors.Wrapf(err, "failed to read %#q document", ns),
+					)
+				}
+
+				if crc != nil {
+					// Writes to a hash never fail.
+					_, _ = crc.Write(raw)
+				}
+
+				var doc bson.D
+				if counts[ns] < decodeLimit {
+					err = bson.Unmarshal(raw, &doc)
+					if err != nil {
+						return bodyStats{}, markError(
+							errors.Wrapf(err, "failed to decode %#q document", ns),
+							ErrCorrupt,
+						)
+					}
+				}
+
+				for i, analyzer := range analyzers {
+					if counts[ns] >= limits[i] {
+						continue
+					}
+
+					if rawAnalyzer, ok := analyzer.(rawDocumentAnalyzer); ok {
+						err = rawAnalyzer.analyzeRaw(header, bson.Raw(raw))
+						if err != nil {
+							return bodyStats{}, errors.Wrapf(err, "failed to handle %#q document", ns)
+						}
+					} else {
+						analyzer.analyze(header, doc)
+					}
+				}
+			case crc != nil:
+				_, err = io.CopyN(crc, blockReader, int64(docLen))
+				if err != nil {
+					return bodyStats{}, markIfTruncated(
+						errors.Wrapf(err, "failed to read %d-byte %#q document", docLen, ns),
+					)
+				}
+			default:
+				// Counting & sizing need only the length prefix, so
+				// skip the document without decoding it.
+				_, err = blockReader.Discard(docLen)
+				if err != nil {
+					return bodyStats{}, markIfTruncated(
+						errors.Wrapf(err, "failed to skip %d-byte %#q document", docLen, ns),
+					)
+				}
+			}
+
+			counts[ns]++
+			sizes[ns] += int64(docLen)
+		}
+
+		err = closeBlock(blockReader, gzipReader)
+		if err != nil {
+			return bodyStats{}, errors.Wrapf(err, "failed to read %#q block", ns)
+		}
+
+		if header.EOF {
+			cr.explain(offset, "read EOF for %s (CRC %d)", ns, header.CRC)
+		} else {
+			compressed := ""
+			if gzipReader != nil {
+				compressed = "gzip-compressed "
+			}
+
+			cr.explain(
+				offset,
+				"read %sblock for %s: %d documents (%d bytes)",
+				compressed, ns, counts[ns]-prevCount, cr.BytesRead()-offset,
+			)
+		}
+	}
+
+	cr.explain(cr.BytesRead(), "reached end of archive")
+
+	return bodyStats{
+		docCounts:         counts,
+		docBytes:          sizes,
+		maxOpenNamespaces: maxOpen,
+		ended:             ended,
+		blocks:            blocks,
+		compression:       compression,
+		trailing:          trailing,
+	}, nil
+}
+
+// getUnendedNamespaces returns the namespaces that should have, but lack,
+// an EOF header: every namespace with body blocks, plus every collection
+// in the metadata. (Views have no body.) mongodump writes an EOF header
+// for each namespace it finishes, so any that lack one suggest that the
+// archive is truncated.
+func getUnendedNamespaces(mdDocs []bson.D, stats bodyStats) []string {
+	var unended []string
+
+	for _, mdDoc := range mdDocs {
+		if getNamespaceType(mdDoc) == "view" {
+			continue
+		}
+
+		db, coll := getNamespace(mdDoc)
+		if ns := db + "." + coll; !stats.ended[ns] {
+			unended = append(unended, ns)
+		}
+	}
+
+	for _, ns := range slices.Sorted(maps.Keys(stats.docCounts)) {
+		if !stats.ended[ns] && !slices.Contains(unended, ns) {
+			unended = append(unended, ns)
+		}
+	}
+
+	return unended
+}
+
+// peekDocumentLength returns the length of the next document in the
+// stream without consuming it. If the stream is at a terminator instead,
+// this consumes the terminator and returns 0.
+func peekDocumentLength(cr *countingReader, maxSize int) (int, error) {
+	next4, err := cr.Peek(4)
+	if err != nil {
+		return 0, markIfTruncated(errors.Wrap(err, "failed to read document length"))
+	}
+
+	if bytes.Equal(next4, terminatorBytes) {
+		_, err := cr.Discard(len(terminatorBytes))
+		return 0, err
+	}
+
+	docLen := int(int32(binary.LittleEndian.Uint32(next4)))
+
+	if docLen < minDocumentSize {
+		return 0, markError(fmt.Errorf("invalid document length (%d)", docLen), ErrCorrupt)
+	}
+
+	if docLen > maxSize {
+		return 0, markError(
+			fmt.Errorf("document length (%d) exceeds maximum (%d)", docLen, maxSize),
+			ErrDocumentTooLarge,
+		)
+	}
+
+	return docLen, nil
+}
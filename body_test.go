@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc64"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/mongodb/mongo-tools/common/archive"
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDocumentCounts(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	report, err := getReport(bytes.NewReader(dump), os.Stderr, ParseOptions{CountDocuments: true})
+	require.NoError(t, err, "should parse dump")
+
+	assert.Equal(
+		t,
+		map[string]int64{
+			"testDB.testColl":      1500,
+			"admin.system.users":   4,
+			"admin.system.roles":   4,
+			"admin.system.version": 2,
+		},
+		report.DocumentCounts,
+		"should count each namespace’s documents",
+	)
+	assert.EqualValues(t, len(dump), report.BytesRead, "should read the entire archive")
+	require.NotNil(t, report.Summary.Documents, "summary should total documents")
+	assert.EqualValues(t, 1510, *report.Summary.Documents, "summary should total documents")
+	require.NotNil(t, report.Summary.Bytes, "summary should total bytes")
+	assert.Positive(t, *report.Summary.Bytes, "summary should total bytes")
+
+	_, err = getReport(
+		bytes.NewReader(dump),
+		os.Stderr,
+		ParseOptions{CountDocuments: true, MaxDocumentSize: 20},
+	)
+	assert.ErrorIs(t, err, ErrDocumentTooLarge, "should enforce maximum document size")
+
+	_, err = getReport(
+		bytes.NewReader(dump[:len(dump)-100]),
+		os.Stderr,
+		ParseOptions{CountDocuments: true},
+	)
+	assert.ErrorIs(t, err, ErrTruncated, "should detect truncated body")
+}
+
+func TestCountNamespaceDocuments(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	count, err := countNamespaceDocuments(bytes.NewReader(dump), io.Discard, "testDB.testColl", ParseOptions{})
+	require.NoError(t, err, "should count documents")
+	assert.EqualValues(t, 1500, count, "should count namespace’s documents")
+
+	count, err = countNamespaceDocuments(bytes.NewReader(dump), io.Discard, "admin.system.version", ParseOptions{})
+	require.NoError(t, err, "should count documents")
+	assert.EqualValues(t, 2, count, "should count namespace’s documents")
+
+	_, err = countNamespaceDocuments(bytes.NewReader(dump), io.Discard, "testDB.nonexistent", ParseOptions{})
+	assert.Error(t, err, "should reject missing namespace")
+
+	_, err = countNamespaceDocuments(bytes.NewReader(dump), io.Discard, "testDB", ParseOptions{})
+	assert.Error(t, err, "should reject malformed namespace")
+}
+
+func TestDocumentHandler(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	type testDoc struct {
+		ID primitive.ObjectID `bson:"_id"`
+		I  int64              `bson:"i"`
+	}
+
+	var docs []testDoc
+	namespaces := map[string]bool{}
+
+	report, err := getReport(bytes.NewReader(dump), io.Discard, ParseOptions{
+		SampleSize:  1,
+		Collections: []string{"testColl"},
+		DocumentHandler: func(ns string, raw bson.Raw) error {
+			namespaces[ns] = true
+
+			var doc testDoc
+			err := bson.Unmarshal(raw, &doc)
+			docs = append(docs, doc)
+
+			return err
+		},
+	})
+	require.NoError(t, err, "should parse dump")
+
+	assert.Equal(t, map[string]bool{"testDB.testColl": true}, namespaces, "should handle only the report’s namespaces")
+	require.Len(t, docs, 1500, "should handle every document")
+	assert.EqualValues(t, 1000, docs[0].I, "should decode into the caller’s type")
+	assert.False(t, docs[0].ID.IsZero(), "should decode into the caller’s type")
+
+	sample := report.Samples["testDB.testColl"][0]
+	i, _ := bsonutil.FindValueByKey("i", &sample)
+	assert.Equal(t, int32(1000), i, "should sample with the default registry")
+
+	errStop := errors.New("stop")
+	calls := 0
+
+	_, err = getReport(bytes.NewReader(dump), io.Discard, ParseOptions{
+		DocumentHandler: func(string, bson.Raw) error {
+			calls++
+			return errStop
+		},
+	})
+	assert.ErrorIs(t, err, errStop, "should fail with the handler’s error")
+	assert.Equal(t, 1, calls, "should stop at the handler’s error")
+}
+
+func TestEstimateSizes(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	report, err := getReport(bytes.NewReader(dump), os.Stderr, ParseOptions{EstimateSizes: true})
+	require.NoError(t, err, "should parse dump")
+
+	assert.Equal(
+		t,
+		map[string]int64{
+			"testDB.testColl":      43500,
+			"admin.system.users":   1552,
+			"admin.system.roles":   3311,
+			"admin.system.version": 104,
+		},
+		report.EstimatedSizes,
+		"should sum each namespace’s document lengths",
+	)
+	assert.Nil(t, report.DocumentCounts, "should not report counts")
+	assert.Nil(t, report.Summary.Documents, "summary should not total documents")
+	require.NotNil(t, report.Summary.Bytes, "summary should total bytes")
+	assert.EqualValues(t, 48467, *report.Summary.Bytes, "summary should total bytes")
+}
+
+func TestVerifyCRC(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	_, err = getReport(bytes.NewReader(dump), os.Stderr, ParseOptions{VerifyCRC: true})
+	require.NoError(t, err, "intact archive should pass CRC verification")
+
+	_, err = getReport(
+		bytes.NewReader(dump),
+		os.Stderr,
+		ParseOptions{VerifyCRC: true, SampleSize: 1},
+	)
+	require.NoError(t, err, "CRC verification should include sampled documents")
+
+	// Alter a string value so the document stays well-formed.
+	pos := bytes.Index(dump, []byte("featureCompatibilityVersion"))
+	require.NotEqual(t, -1, pos, "dump should contain the FCV document")
+
+	corrupted := bytes.Clone(dump)
+	corrupted[pos] = 'F'
+
+	_, err = getReport(bytes.NewReader(corrupted), os.Stderr, ParseOptions{VerifyCRC: true})
+	assert.ErrorIs(t, err, ErrCRCMismatch, "should detect altered document")
+	assert.Equal(t, exitCRCMismatch, exitCode(err), "should map to CRC exit status")
+
+	_, err = getReport(bytes.NewReader(corrupted), os.Stderr, ParseOptions{CountDocuments: true})
+	assert.NoError(t, err, "should not check CRCs unless asked")
+}
+
+func TestDocumentCountsGzip(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	// This is how `mongodump --archive --gzip` compresses its output.
+	compressed := bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(&compressed)
+	_, err = gzipWriter.Write(dump)
+	require.NoError(t, err, "should compress dump")
+	require.NoError(t, gzipWriter.Close(), "should compress dump")
+
+	report, err := getReport(&compressed, os.Stderr, ParseOptions{CountDocuments: true})
+	require.NoError(t, err, "should parse compressed dump")
+
+	assert.Equal(t, "gzip", report.Compression, "should note compression")
+	assert.EqualValues(t, 1500, report.DocumentCounts["testDB.testColl"], "should count documents")
+	assert.EqualValues(t, len(dump), report.BytesRead, "should count uncompressed bytes")
+}
+
+func TestOpenNamespaces(t *testing.T) {
+	header := bson.D{{Key: "concurrent_collections", Value: int32(2)}}
+	mdDocs := []bson.D{
+		makeMetadataDoc("db", "a"),
+		makeMetadataDoc("db", "b"),
+		makeMetadataDoc("db", "c"),
+	}
+	doc := bson.D{{Key: "_id", Value: 1}}
+
+	within := makeArchive(t, header, mdDocs, []testBlock{
+		{db: "db", coll: "a", docs: []bson.D{doc}},
+		{db: "db", coll: "b", docs: []bson.D{doc}},
+		{db: "db", coll: "a", eof: true},
+		{db: "db", coll: "c", docs: []bson.D{doc}},
+		{db: "db", coll: "b", eof: true},
+		{db: "db", coll: "c", eof: true},
+	})
+
+	warnings := bytes.Buffer{}
+	report, err := getReport(bytes.NewReader(within), &warnings, ParseOptions{VerifyCRC: true})
+	require.NoError(t, err, "should parse archive")
+	assert.Equal(t, 2, report.ConcurrentCollections, "should report concurrent_collections")
+	assert.Empty(t, warnings.String(), "should not warn")
+
+	exceeding := makeArchive(t, header, mdDocs, []testBlock{
+		{db: "db", coll: "a", docs: []bson.D{doc}},
+		{db: "db", coll: "b", docs: []bson.D{doc}},
+		{db: "db", coll: "c", docs: []bson.D{doc}},
+		{db: "db", coll: "a", eof: true},
+		{db: "db", coll: "b", eof: true},
+		{db: "db", coll: "c", eof: true},
+	})
+
+	warnings.Reset()
+	_, err = getReport(bytes.NewReader(exceeding), &warnings, ParseOptions{CountDocuments: true})
+	require.NoError(t, err, "should parse archive")
+	assert.Contains(t, warnings.String(), "3 namespaces open at once", "should warn")
+}
+
+func TestComplete(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	report, err := getReport(bytes.NewReader(dump), io.Discard, ParseOptions{CheckComplete: true})
+	require.NoError(t, err, "should parse dump")
+	require.NotNil(t, report.Complete, "should report completeness")
+	assert.True(t, *report.Complete, "test dump should be complete")
+
+	report, err = getReport(bytes.NewReader(dump), io.Discard, ParseOptions{})
+	require.NoError(t, err, "should parse dump")
+	assert.Nil(t, report.Complete, "should not report completeness without reading the body")
+
+	mdDocs := []bson.D{
+		makeMetadataDoc("db", "a"),
+		makeMetadataDoc("db", "empty"),
+		append(makeMetadataDoc("db", "view"), bson.E{Key: "type", Value: "view"}),
+	}
+	doc := bson.D{{Key: "_id", Value: 1}}
+
+	complete := makeArchive(t, bson.D{}, mdDocs, []testBlock{
+		{db: "db", coll: "a", docs: []bson.D{doc}},
+		{db: "db", coll: "a", eof: true},
+		{db: "db", coll: "empty", eof: true},
+	})
+
+	report, err = getReport(bytes.NewReader(complete), io.Discard, ParseOptions{CheckComplete: true})
+	require.NoError(t, err, "should parse archive")
+	assert.True(t, *report.Complete, "views need no EOF header")
+
+	incomplete := makeArchive(t, bson.D{}, mdDocs, []testBlock{
+		{db: "db", coll: "a", docs: []bson.D{doc}},
+		{db: "db", coll: "other", docs: []bson.D{doc}},
+		{db: "db", coll: "other", eof: true},
+	})
+
+	warnings := bytes.Buffer{}
+	report, err = getReport(bytes.NewReader(incomplete), &warnings, ParseOptions{CountDocuments: true})
+	require.NoError(t, err, "should parse archive")
+	assert.False(t, *report.Complete, "should detect missing EOF headers")
+	assert.Contains(t, warnings.String(), "archive lacks EOF headers for [db.a db.empty]", "should warn")
+
+	_, err = getReport(bytes.NewReader(incomplete), io.Discard, ParseOptions{CheckComplete: true, Strict: true})
+	assert.ErrorIs(t, err, ErrStrict, "strict mode should reject incomplete archive")
+}
+
+// testBlock describes a namespace block for makeArchive.
+type testBlock struct {
+	db, coll string
+	eof      bool
+	docs     []bson.D
+
+	// gzip compresses the block’s documents & terminator.
+	gzip bool
+}
+
+// makeArchive assembles a mongodump archive. The header gets the current
+// format version unless it has one. Any parsed metadata in mdDocs is
+// re-encoded as Extended JSON, and EOF blocks get the CRC of their
+// namespace’s preceding documents.
+func makeArchive(t testing.TB, header bson.D, mdDocs []bson.D, blocks []testBlock) []byte {
+	buf := bytes.Buffer{}
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, archive.MagicNumber), "should write magic")
+
+	if _, err := bsonutil.FindValueByKey("version", &header); err != nil {
+		header = append(bson.D{{Key: "version", Value: archiveVersion}}, header...)
+	}
+
+	writeDoc := func(doc any) []byte {
+		raw, err := bson.Marshal(doc)
+		require.NoError(t, err, "should marshal %v", doc)
+		buf.Write(raw)
+
+		return raw
+	}
+
+	writeDoc(header)
+
+	for _, mdDoc := range mdDocs {
+		mdDoc = slices.Clone(mdDoc)
+		for i := range mdDoc {
+			if metadata, ok := mdDoc[i].Value.(bson.D); ok && mdDoc[i].Key == "metadata" {
+				json, err := bson.MarshalExtJSON(metadata, false, false)
+				require.NoError(t, err, "should marshal metadata")
+				mdDoc[i].Value = string(json)
+			}
+		}
+
+		writeDoc(mdDoc)
+	}
+	buf.Write(terminatorBytes)
+
+	crcs := map[string]hash.Hash64{}
+	for _, block := range blocks {
+		ns := block.db + "." + block.coll
+		if crcs[ns] == nil {
+			crcs[ns] = crc64.New(crc64.MakeTable(crc64.ECMA))
+		}
+
+		nsHeader := archive.NamespaceHeader{
+			Database:   block.db,
+			Collection: block.coll,
+			EOF:        block.eof,
+		}
+		if block.eof {
+			nsHeader.CRC = int64(crcs[ns].Sum64())
+		}
+		writeDoc(nsHeader)
+
+		start := buf.Len()
+		for _, doc := range block.docs {
+			_, _ = crcs[ns].Write(writeDoc(doc))
+		}
+		buf.Write(terminatorBytes)
+
+		if block.gzip {
+			body := slices.Clone(buf.Bytes()[start:])
+			buf.Truncate(start)
+
+			gzipWriter := gzip.NewWriter(&buf)
+			_, err := gzipWriter.Write(body)
+			require.NoError(t, err, "should compress block")
+			require.NoError(t, gzipWriter.Close(), "should compress block")
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func TestCountBlocks(t *testing.T) {
+	doc := bson.D{{Key: "_id", Value: 1}}
+
+	dump := makeArchive(
+		t,
+		bson.D{},
+		[]bson.D{makeMetadataDoc("db", "a"), makeMetadataDoc("db", "b"), makeMetadataDoc("db", "empty")},
+		[]testBlock{
+			{db: "db", coll: "a", docs: []bson.D{doc}},
+			{db: "db", coll: "b", docs: []bson.D{doc}},
+			{db: "db", coll: "a", docs: []bson.D{doc, doc}},
+			{db: "db", coll: "a", docs: []bson.D{doc}},
+			{db: "db", coll: "a", eof: true},
+			{db: "db", coll: "b", eof: true},
+			{db: "db", coll: "empty", eof: true},
+		},
+	)
+
+	report, err := getReport(bytes.NewReader(dump), io.Discard, ParseOptions{CountBlocks: true, Collections: []string{"a", "empty"}})
+	require.NoError(t, err, "should parse archive")
+	assert.Equal(t, map[string]int{"db.a": 3, "db.empty": 0}, report.Blocks, "should count data blocks, not EOF")
+}
+
+// decodingAnalyzer makes readBody decode every document but does nothing
+// with them.
+type decodingAnalyzer struct{}
+
+func (decodingAnalyzer) docLimit(archive.NamespaceHeader) int {
+	return math.MaxInt
+}
+
+func (decodingAnalyzer) analyze(archive.NamespaceHeader, bson.D) {}
+
+// BenchmarkCountDocuments compares counting documents, which skips them
+// by their length prefixes, with decoding every document.
+func BenchmarkCountDocuments(b *testing.B) {
+	docs := make([]bson.D, 1000)
+	for i := range docs {
+		docs[i] = bson.D{
+			{Key: "_id", Value: int32(i)},
+			{Key: "name", Value: "document"},
+			{Key: "tags", Value: bson.A{"a", "b", "c"}},
+			{Key: "nested", Value: bson.D{{Key: "x", Value: 1.5}, {Key: "y", Value: true}}},
+		}
+	}
+
+	var blocks []testBlock
+	for range 10 {
+		blocks = append(blocks, testBlock{db: "db", coll: "coll", docs: docs})
+	}
+	blocks = append(blocks, testBlock{db: "db", coll: "coll", eof: true})
+
+	// readBody starts after the metadata terminator.
+	prefixLen := len(makeArchive(b, bson.D{}, nil, nil))
+	body := makeArchive(b, bson.D{}, nil, blocks)[prefixLen:]
+
+	for _, bc := range []struct {
+		name      string
+		analyzers []documentAnalyzer
+	}{
+		{"skip", nil},
+		{"decode", []documentAnalyzer{decodingAnalyzer{}}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(body)))
+
+			for range b.N {
+				cr := newCountingReader(bufio.NewReader(bytes.NewReader(body)))
+
+				stats, err := readBody(cr, ParseOptions{CountDocuments: true}, nil, bc.analyzers)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				if stats.docCounts["db.coll"] != 10_000 {
+					b.Fatalf("counted %d documents", stats.docCounts["db.coll"])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"github.com/mongodb/mongo-tools/common/util"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CappedOverflow describes a capped collection whose archived documents
+// exceed the collection’s declared cap. Restoring such a collection
+// silently drops its oldest documents.
+type CappedOverflow struct {
+	DB         string `bson:"db"`
+	Collection string `bson:"collection"`
+
+	// MaxBytes & MaxDocuments are the cap (i.e., the “size” & “max”
+	// options). Either may be absent.
+	MaxBytes     *int64 `bson:"maxBytes,omitempty"`
+	MaxDocuments *int64 `bson:"maxDocuments,omitempty"`
+
+	// Bytes & Documents are what the archive body contains. Documents is
+	// absent unless documents were counted.
+	Bytes     int64  `bson:"bytes"`
+	Documents *int64 `bson:"documents,omitempty"`
+}
+
+// getCappedOverflows compares each capped collection’s cap with the size
+// (and, if docCounts is non-nil, the count) of its archived documents.
+func getCappedOverflows(mdDocs []bson.D, docCounts, docBytes map[string]int64) []CappedOverflow {
+	var overflows []CappedOverflow
+
+	for _, mdDoc := range mdDocs {
+		if !isCapped(mdDoc) {
+			continue
+		}
+
+		db, coll := getNamespace(mdDoc)
+		ns := db + "." + coll
+		options := getOptions(mdDoc)
+
+		overflow := CappedOverflow{
+			DB:           db,
+			Collection:   coll,
+			MaxBytes:     getCapOption(options, "size"),
+			MaxDocuments: getCapOption(options, "max"),
+			Bytes:        docBytes[ns],
+		}
+
+		exceeded := overflow.MaxBytes != nil && overflow.Bytes > *overflow.MaxBytes
+
+		if docCounts != nil {
+			count := docCounts[ns]
+			overflow.Documents = &count
+
+			if overflow.MaxDocuments != nil && count > *overflow.MaxDocuments {
+				exceeded = true
+			}
+		}
+
+		if exceeded {
+			overflows = append(overflows, overflow)
+		}
+	}
+
+	return overflows
+}
+
+// getCapOption returns a capped collection’s numeric option, or nil if it
+// is missing or non-positive. (The server ignores a nonpositive “max”.)
+func getCapOption(options bson.D, key string) *int64 {
+	value, err := bsonutil.FindValueByKey(key, &options)
+	if err != nil {
+		return nil
+	}
+
+	num, err := util.ToInt(value)
+	if err != nil || num <= 0 {
+		return nil
+	}
+
+	num64 := int64(num)
+
+	return &num64
+}
+
+// describe summarizes the overflow for a warning message.
+func (o CappedOverflow) describe() string {
+	var capParts, observedParts []string
+
+	if o.MaxBytes != nil {
+		capParts = append(capParts, fmt.Sprintf("%d bytes", *o.MaxBytes))
+	}
+
+	if o.MaxDocuments != nil {
+		capParts = append(capParts, fmt.Sprintf("%d documents", *o.MaxDocuments))
+	}
+
+	observedParts = append(observedParts, fmt.Sprintf("%d bytes", o.Bytes))
+
+	if o.Documents != nil {
+		observedParts = append(observedParts, fmt.Sprintf("%d documents", *o.Documents))
+	}
+
+	return fmt.Sprintf(
+		"capped collection %s.%s holds %s, but its cap is %s",
+		o.DB,
+		o.Collection,
+		strings.Join(observedParts, " in "),
+		strings.Join(capParts, " or "),
+	)
+}
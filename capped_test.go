@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCappedOverflows(t *testing.T) {
+	mdDocs := []bson.D{
+		makeMetadataDocWithOptions("db", "bySize", bson.D{
+			{Key: "capped", Value: true},
+			{Key: "size", Value: int32(100)},
+		}),
+		makeMetadataDocWithOptions("db", "byMax", bson.D{
+			{Key: "capped", Value: true},
+			{Key: "size", Value: int64(1 << 30)},
+			{Key: "max", Value: float64(2)},
+		}),
+		makeMetadataDocWithOptions("db", "roomy", bson.D{
+			{Key: "capped", Value: true},
+			{Key: "size", Value: int32(4096)},
+		}),
+		makeMetadataDocWithOptions("db", "uncapped", bson.D{}),
+	}
+	docCounts := map[string]int64{"db.bySize": 1, "db.byMax": 3, "db.roomy": 3, "db.uncapped": 100}
+	docBytes := map[string]int64{"db.bySize": 150, "db.byMax": 60, "db.roomy": 60, "db.uncapped": 5000}
+
+	sizeCap, docCap := int64(100), int64(2)
+	count := int64(3)
+
+	assert.Equal(
+		t,
+		[]CappedOverflow{
+			{DB: "db", Collection: "bySize", MaxBytes: &sizeCap, Bytes: 150},
+		},
+		getCappedOverflows(mdDocs, nil, docBytes),
+		"should check only size without counts",
+	)
+
+	overflows := getCappedOverflows(mdDocs, docCounts, docBytes)
+	require.Len(t, overflows, 2, "should find both overflows")
+	assert.Equal(t, "bySize", overflows[0].Collection, "should flag size overflow")
+	assert.Equal(
+		t,
+		CappedOverflow{
+			DB:           "db",
+			Collection:   "byMax",
+			MaxBytes:     overflows[1].MaxBytes,
+			MaxDocuments: &docCap,
+			Bytes:        60,
+			Documents:    &count,
+		},
+		overflows[1],
+		"should flag document-count overflow",
+	)
+	assert.Equal(
+		t,
+		"capped collection db.byMax holds 60 bytes in 3 documents, but its cap is 1073741824 bytes or 2 documents",
+		overflows[1].describe(),
+		"should describe overflow",
+	)
+}
+
+func TestCappedOverflowWarning(t *testing.T) {
+	doc := bson.D{{Key: "padding", Value: "0123456789"}}
+
+	dump := makeArchive(
+		t,
+		bson.D{},
+		[]bson.D{
+			makeMetadataDocWithOptions("db", "log", bson.D{
+				{Key: "capped", Value: true},
+				{Key: "size", Value: int32(30)},
+			}),
+		},
+		[]testBlock{
+			{db: "db", coll: "log", docs: []bson.D{doc, doc}},
+			{db: "db", coll: "log", eof: true},
+		},
+	)
+
+	warnings := bytes.Buffer{}
+	report, err := getReport(bytes.NewReader(dump), &warnings, ParseOptions{EstimateSizes: true})
+	require.NoError(t, err, "should parse archive")
+
+	require.Len(t, report.CappedOverflows, 1, "should report overflow")
+	assert.Contains(t, warnings.String(), "capped collection db.log holds", "should warn")
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/urfave/cli/v3"
+	"golang.org/x/term"
+)
+
+// These are the --color modes.
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+var colorModes = []string{colorAuto, colorAlways, colorNever}
+
+func validateColorMode(mode string) error {
+	if !slices.Contains(colorModes, mode) {
+		return fmt.Errorf("color mode must be one of %v, not %#q", colorModes, mode)
+	}
+
+	return nil
+}
+
+// getColorMode returns the command’s --color mode. --no-color is the
+// same as --color=never.
+func getColorMode(cmd *cli.Command) string {
+	if cmd.Bool("no-color") {
+		return colorNever
+	}
+
+	return cmd.String("color")
+}
+
+// useColor indicates whether output to file should be colorized per the
+// given mode. In auto mode, a non-empty $NO_COLOR disables color, as
+// https://no-color.org describes.
+func useColor(mode string, file *os.File) bool {
+	switch mode {
+	case colorAlways:
+		return true
+	case colorNever:
+		return false
+	default:
+		return os.Getenv("NO_COLOR") == "" && term.IsTerminal(int(file.Fd()))
+	}
+}
+
+// These ANSI escape sequences are all the same width, which lets
+// tabwriter align colorized rows. (It counts escape sequences as text.)
+const (
+	ansiReset = "\x1b[00m"
+	ansiBold  = "\x1b[01m"
+	ansiDim   = "\x1b[02m"
+	ansiCyan  = "\x1b[36m"
+)
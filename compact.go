@@ -0,0 +1,53 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// compactMetadata returns a copy of a collection metadata document whose
+// index specifications have only their names. mdDoc is unchanged, as are
+// documents whose metadata didn’t parse or that lack indexes.
+func compactMetadata(mdDoc bson.D) bson.D {
+	metadata, ok := getParsedMetadata(mdDoc)
+	if !ok {
+		return mdDoc
+	}
+
+	if _, err := bsonutil.FindValueByKey("indexes", &metadata); err != nil {
+		return mdDoc
+	}
+
+	indexes := getIndexes(mdDoc)
+	names := make(bson.A, 0, len(indexes))
+
+	for _, index := range indexes {
+		// RemoveKey shifts elements in place, so work on a copy.
+		compact := slices.Clone(index)
+
+		for _, elem := range index {
+			if elem.Key != "name" {
+				bsonutil.RemoveKey(elem.Key, &compact)
+			}
+		}
+
+		names = append(names, compact)
+	}
+
+	return replaceValue(mdDoc, "metadata", replaceValue(metadata, "indexes", names))
+}
+
+// replaceValue returns a copy of doc with key’s value replaced.
+func replaceValue(doc bson.D, key string, value any) bson.D {
+	doc = slices.Clone(doc)
+
+	for i := range doc {
+		if doc[i].Key == key {
+			doc[i].Value = value
+		}
+	}
+
+	return doc
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCompactMetadata(t *testing.T) {
+	makeDoc := func() bson.D {
+		return makeMetadataDocWithIndexes("db", "coll", bson.D{{Key: "capped", Value: true}}, bson.A{
+			bson.D{
+				{Key: "v", Value: int32(2)},
+				{Key: "key", Value: bson.D{{Key: "a", Value: int32(1)}}},
+				{Key: "name", Value: "a_1"},
+				{Key: "unique", Value: true},
+			},
+		})
+	}
+	mdDoc := makeDoc()
+
+	assert.Equal(
+		t,
+		makeMetadataDocWithIndexes("db", "coll", bson.D{{Key: "capped", Value: true}}, bson.A{bson.D{{Key: "name", Value: "a_1"}}}),
+		compactMetadata(mdDoc),
+		"should keep only index names",
+	)
+	assert.Equal(t, makeDoc(), mdDoc, "should not modify the original")
+
+	view := makeMetadataDoc("db", "view")
+	assert.Equal(t, view, compactMetadata(view), "should leave documents without indexes alone")
+
+	dump := makeArchive(t, bson.D{}, []bson.D{mdDoc}, nil)
+
+	report, err := getReport(bytes.NewReader(dump), &bytes.Buffer{}, ParseOptions{CompactMetadata: true})
+	require.NoError(t, err, "should parse archive")
+
+	assert.Equal(t, []bson.D{compactMetadata(mdDoc)}, report.CollectionMetadata, "should compact metadata")
+	assert.Equal(t, 1, report.Summary.Indexes, "should count indexes before compacting")
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"github.com/mongodb/mongo-tools/common/util"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CollectionDetails summarizes noteworthy properties of a namespace that
+// would otherwise be buried in its metadata. The report includes these
+// only for namespaces that have at least one such property.
+type CollectionDetails struct {
+	DB         string `bson:"db"`
+	Collection string `bson:"collection"`
+
+	ClusteredIndex *ClusteredIndex `bson:"clusteredIndex,omitempty"`
+	Collation      bson.D          `bson:"collation,omitempty"`
+	HiddenIndexes  []string        `bson:"hiddenIndexes,omitempty"`
+	ShardKey       bson.D          `bson:"shardKey,omitempty"`
+	StorageEngine  bson.D          `bson:"storageEngine,omitempty"`
+
+	// SpecialIndexOptions lists the geospatial & text indexes’ options
+	// (see specialIndexOptions), which are easy to lose track of.
+	SpecialIndexOptions []SpecialIndexOptions `bson:"specialIndexOptions,omitempty"`
+
+	// WildcardIndexes lists the wildcard indexes, which restore and query
+	// planning treat differently from other indexes.
+	WildcardIndexes []WildcardIndex `bson:"wildcardIndexes,omitempty"`
+
+	// Validator, ValidationLevel, & ValidationAction are the collection’s
+	// schema validation options, if any.
+	Validator        bson.D `bson:"validator,omitempty"`
+	ValidationLevel  string `bson:"validationLevel,omitempty"`
+	ValidationAction string `bson:"validationAction,omitempty"`
+
+	// AutoIndexID is the legacy autoIndexId option, if present.
+	AutoIndexID *bool `bson:"autoIndexId,omitempty"`
+
+	// LegacyFlags decodes the legacy (MMAPv1-era) flags option, if
+	// present.
+	LegacyFlags *LegacyFlags `bson:"legacyFlags,omitempty"`
+
+	// IDIndex is the collection’s explicit _id index specification, if
+	// any. Most collections omit this and get the default _id index.
+	IDIndex bson.D `bson:"idIndex,omitempty"`
+
+	// NoIDIndex indicates that autoIndexId disables the _id index and
+	// the archive doesn’t include one, so the restored collection won’t
+	// have one.
+	NoIDIndex bool `bson:"noIdIndex,omitempty"`
+
+	// TimeSeriesBuckets is, for a time-series collection, the name of the
+	// internal collection that stores its buckets. TimeSeriesView is the
+	// reverse: for a buckets collection, the time-series collection whose
+	// buckets it stores.
+	TimeSeriesBuckets string `bson:"timeSeriesBuckets,omitempty"`
+	TimeSeriesView    string `bson:"timeSeriesView,omitempty"`
+
+	// EncryptedFields is the encryptedFields option of a collection that
+	// uses Queryable Encryption. EncryptionStateCollections are the
+	// names of that collection’s state collections (e.g.,
+	// enxcol_.COLLECTION.esc) that the archive contains. EncryptedCollection
+	// is the reverse: for a state collection, the encrypted collection
+	// whose state it holds.
+	EncryptedFields            bson.D   `bson:"encryptedFields,omitempty"`
+	EncryptionStateCollections []string `bson:"encryptionStateCollections,omitempty"`
+	EncryptedCollection        string   `bson:"encryptedCollection,omitempty"`
+}
+
+// timeSeriesBucketsPrefix prefixes the name of each time-series
+// collection’s buckets collection.
+const timeSeriesBucketsPrefix = "system.buckets."
+
+// encryptionStateFields maps each encryptedFields field that can
+// name a Queryable Encryption state collection to the suffix of that
+// collection’s default name, enxcol_.COLLECTION.SUFFIX. (Servers before
+// 7.0 also used an ECC collection.)
+var encryptionStateFields = []struct{ field, suffix string }{
+	{"escCollection", "esc"},
+	{"eccCollection", "ecc"},
+	{"ecocCollection", "ecoc"},
+}
+
+// encryptionStatePrefix prefixes the default names of Queryable
+// Encryption state collections.
+const encryptionStatePrefix = "enxcol_."
+
+// SpecialIndexOptions are one index’s geospatial or text options.
+type SpecialIndexOptions struct {
+	Name    string `bson:"name"`
+	Options bson.D `bson:"options"`
+}
+
+// specialIndexOptions are the index options that only geospatial & text
+// indexes have.
+var specialIndexOptions = []string{
+	// 2dsphere
+	"2dsphereIndexVersion",
+
+	// 2d
+	"bits",
+	"min",
+	"max",
+
+	// text
+	"textIndexVersion",
+	"weights",
+	"default_language",
+	"language_override",
+}
+
+// WildcardIndex describes a wildcard index, i.e., one whose key pattern
+// includes “$**” or a path ending in “.$**”.
+type WildcardIndex struct {
+	Name string `bson:"name"`
+	Key  bson.D `bson:"key"`
+
+	// WildcardProjection is the fields that a “$**” index includes or
+	// excludes, if it says.
+	WildcardProjection bson.D `bson:"wildcardProjection,omitempty"`
+}
+
+// wildcardKeyField is the key pattern field of an index on all fields.
+const wildcardKeyField = "$**"
+
+// LegacyFlags decodes the flags collection option that servers before
+// 4.2 recorded. Its bits mirror the usePowerOf2Sizes & noPadding options.
+type LegacyFlags struct {
+	Value            int64 `bson:"value"`
+	UsePowerOf2Sizes bool  `bson:"usePowerOf2Sizes"`
+	NoPadding        bool  `bson:"noPadding"`
+
+	// UnknownBits are any set bits that neither of the above explains.
+	UnknownBits int64 `bson:"unknownBits,omitempty"`
+}
+
+// These are the bits of the legacy flags option.
+const (
+	flagUsePowerOf2Sizes = 1 << 0
+	flagNoPadding        = 1 << 1
+)
+
+// ClusteredIndex describes a clustered collection’s clustered index.
+type ClusteredIndex struct {
+	Key  bson.D `bson:"key"`
+	Name string `bson:"name,omitempty"`
+}
+
+// getCollectionDetails returns details for each collection metadata
+// document that has any. shardKeys maps namespaces to shard key patterns
+// found elsewhere in the archive.
+func getCollectionDetails(mdDocs []bson.D, shardKeys map[string]bson.D) []CollectionDetails {
+	var allDetails []CollectionDetails
+
+	timeSeries := getTimeSeriesPairs(mdDocs)
+	stateCollections, encryptedCollections := getEncryptionStatePairs(mdDocs)
+
+	for _, mdDoc := range mdDocs {
+		details := CollectionDetails{}
+		details.DB, details.Collection = getNamespace(mdDoc)
+
+		options := getOptions(mdDoc)
+
+		details.ClusteredIndex = getClusteredIndex(options)
+		details.Collation = getSubdocument(options, "collation")
+		details.HiddenIndexes = getHiddenIndexes(mdDoc)
+		details.SpecialIndexOptions = getSpecialIndexOptions(mdDoc)
+		details.WildcardIndexes = getWildcardIndexes(mdDoc)
+
+		details.ShardKey = getSubdocument(options, "shardKey")
+		if details.ShardKey == nil {
+			details.ShardKey = shardKeys[details.DB+"."+details.Collection]
+		}
+
+		if storageEngine := getSubdocument(options, "storageEngine"); len(storageEngine) > 0 {
+			details.StorageEngine = storageEngine
+		}
+
+		if validator := getSubdocument(options, "validator"); len(validator) > 0 {
+			details.Validator = validator
+		}
+		details.ValidationLevel, _ = bsonutil.FindStringValueByKey("validationLevel", &options)
+		details.ValidationAction, _ = bsonutil.FindStringValueByKey("validationAction", &options)
+
+		details.AutoIndexID = getAutoIndexID(options)
+		details.LegacyFlags = getLegacyFlags(options)
+		details.IDIndex = getIDIndex(mdDoc)
+		details.NoIDIndex = details.AutoIndexID != nil && !*details.AutoIndexID &&
+			details.IDIndex == nil && !hasIDIndex(mdDoc)
+
+		if buckets, ok := strings.CutPrefix(details.Collection, timeSeriesBucketsPrefix); ok {
+			if timeSeries[details.DB+"."+buckets] {
+				details.TimeSeriesView = buckets
+			}
+		} else if timeSeries[details.DB+"."+details.Collection] {
+			details.TimeSeriesBuckets = timeSeriesBucketsPrefix + details.Collection
+		}
+
+		details.EncryptedFields = getSubdocument(options, "encryptedFields")
+		details.EncryptionStateCollections = stateCollections[details.DB+"."+details.Collection]
+		details.EncryptedCollection = encryptedCollections[details.DB+"."+details.Collection]
+
+		if !reflect.DeepEqual(details, CollectionDetails{DB: details.DB, Collection: details.Collection}) {
+			allDetails = append(allDetails, details)
+		}
+	}
+
+	return allDetails
+}
+
+// getTimeSeriesPairs returns the namespaces of the time-series
+// collections whose buckets collections are also in mdDocs.
+func getTimeSeriesPairs(mdDocs []bson.D) map[string]bool {
+	namespaces := map[string]bool{}
+
+	for _, mdDoc := range mdDocs {
+		db, coll := getNamespace(mdDoc)
+		namespaces[db+"."+coll] = true
+	}
+
+	pairs := map[string]bool{}
+
+	for _, mdDoc := range mdDocs {
+		db, coll := getNamespace(mdDoc)
+
+		if view, ok := strings.CutPrefix(coll, timeSeriesBucketsPrefix); ok && namespaces[db+"."+view] {
+			pairs[db+"."+view] = true
+		}
+	}
+
+	return pairs
+}
+
+// getEncryptionStatePairs pairs Queryable Encryption collections with
+// their state collections in mdDocs. It returns the state collections’
+// names by encrypted namespace and the encrypted collections’ names by
+// state namespace.
+func getEncryptionStatePairs(mdDocs []bson.D) (map[string][]string, map[string]string) {
+	namespaces := map[string]bool{}
+
+	for _, mdDoc := range mdDocs {
+		db, coll := getNamespace(mdDoc)
+		namespaces[db+"."+coll] = true
+	}
+
+	stateCollections := map[string][]string{}
+	encryptedCollections := map[string]string{}
+
+	for _, mdDoc := range mdDocs {
+		encryptedFields := getSubdocument(getOptions(mdDoc), "encryptedFields")
+		if encryptedFields == nil {
+			continue
+		}
+
+		db, coll := getNamespace(mdDoc)
+
+		for _, state := range encryptionStateFields {
+			name, err := bsonutil.FindStringValueByKey(state.field, &encryptedFields)
+			if err != nil || name == "" {
+				name = encryptionStatePrefix + coll + "." + state.suffix
+			}
+
+			if namespaces[db+"."+name] {
+				stateCollections[db+"."+coll] = append(stateCollections[db+"."+coll], name)
+				encryptedCollections[db+"."+name] = coll
+			}
+		}
+	}
+
+	return stateCollections, encryptedCollections
+}
+
+func getClusteredIndex(options bson.D) *ClusteredIndex {
+	value, err := bsonutil.FindValueByKey("clusteredIndex", &options)
+	if err != nil {
+		return nil
+	}
+
+	switch spec := value.(type) {
+	case bson.D:
+		key, _ := bsonutil.FindSubdocumentByKey("key", &spec)
+		name, _ := bsonutil.FindStringValueByKey("name", &spec)
+
+		return &ClusteredIndex{Key: key, Name: name}
+	case bool:
+		// Time-series buckets collections just say `clusteredIndex: true`,
+		// which implies clustering on _id.
+		if spec {
+			return &ClusteredIndex{Key: bson.D{{Key: "_id", Value: int32(1)}}}
+		}
+	}
+
+	return nil
+}
+
+// getHiddenIndexes returns the names of a collection’s hidden indexes.
+func getHiddenIndexes(mdDoc bson.D) []string {
+	var names []string
+
+	for _, index := range getIndexes(mdDoc) {
+		if hidden, _ := bsonutil.FindValueByKey("hidden", &index); hidden != true {
+			continue
+		}
+
+		name, _ := bsonutil.FindStringValueByKey("name", &index)
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func getSpecialIndexOptions(mdDoc bson.D) []SpecialIndexOptions {
+	var allOptions []SpecialIndexOptions
+
+	for _, index := range getIndexes(mdDoc) {
+		options := SpecialIndexOptions{}
+		options.Name, _ = bsonutil.FindStringValueByKey("name", &index)
+
+		for _, elem := range index {
+			if slices.Contains(specialIndexOptions, elem.Key) {
+				options.Options = append(options.Options, elem)
+			}
+		}
+
+		if len(options.Options) > 0 {
+			allOptions = append(allOptions, options)
+		}
+	}
+
+	return allOptions
+}
+
+func getWildcardIndexes(mdDoc bson.D) []WildcardIndex {
+	var wildcards []WildcardIndex
+
+	for _, index := range getIndexes(mdDoc) {
+		key := getSubdocument(index, "key")
+		projection := getSubdocument(index, "wildcardProjection")
+
+		isWildcard := projection != nil || slices.ContainsFunc(key, func(elem bson.E) bool {
+			return elem.Key == wildcardKeyField || strings.HasSuffix(elem.Key, "."+wildcardKeyField)
+		})
+		if !isWildcard {
+			continue
+		}
+
+		wildcard := WildcardIndex{Key: key, WildcardProjection: projection}
+		wildcard.Name, _ = bsonutil.FindStringValueByKey("name", &index)
+
+		wildcards = append(wildcards, wildcard)
+	}
+
+	return wildcards
+}
+
+func getAutoIndexID(options bson.D) *bool {
+	value, _ := bsonutil.FindValueByKey("autoIndexId", &options)
+
+	autoIndexID, ok := value.(bool)
+	if !ok {
+		return nil
+	}
+
+	return &autoIndexID
+}
+
+// getLegacyFlags decodes the legacy flags option, or returns nil if it is
+// absent or not a number.
+func getLegacyFlags(options bson.D) *LegacyFlags {
+	value, err := bsonutil.FindValueByKey("flags", &options)
+	if err != nil {
+		return nil
+	}
+
+	num, err := util.ToInt(value)
+	if err != nil {
+		return nil
+	}
+
+	flags := int64(num)
+
+	return &LegacyFlags{
+		Value:            flags,
+		UsePowerOf2Sizes: flags&flagUsePowerOf2Sizes != 0,
+		NoPadding:        flags&flagNoPadding != 0,
+		UnknownBits:      flags &^ (flagUsePowerOf2Sizes | flagNoPadding),
+	}
+}
+
+// getIDIndex returns the explicit idIndex from a collection’s metadata or,
+// failing that, its options.
+func getIDIndex(mdDoc bson.D) bson.D {
+	metadata, _ := getParsedMetadata(mdDoc)
+
+	if idIndex := getSubdocument(metadata, "idIndex"); idIndex != nil {
+		return idIndex
+	}
+
+	return getSubdocument(getOptions(mdDoc), "idIndex")
+}
+
+// hasIDIndex indicates whether the collection’s indexes include one on
+// just _id.
+func hasIDIndex(mdDoc bson.D) bool {
+	for _, index := range getIndexes(mdDoc) {
+		key := getSubdocument(index, "key")
+		if len(key) == 1 && key[0].Key == "_id" {
+			return true
+		}
+	}
+
+	return false
+}
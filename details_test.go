@@ -0,0 +1,414 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mongodb/mongo-tools/common/archive"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCollectionDetailsClusteredIndex(t *testing.T) {
+	details := getCollectionDetails([]bson.D{
+		makeMetadataDoc("testDB", "plain"),
+		makeMetadataDocWithOptions("testDB", "clustered", bson.D{
+			{Key: "clusteredIndex", Value: bson.D{
+				{Key: "v", Value: int32(2)},
+				{Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}},
+				{Key: "name", Value: "myClusteredIndex"},
+				{Key: "unique", Value: true},
+			}},
+		}),
+		makeMetadataDocWithOptions("testDB", "system.buckets.weather", bson.D{
+			{Key: "clusteredIndex", Value: true},
+		}),
+	}, nil)
+
+	assert.Equal(
+		t,
+		[]CollectionDetails{
+			{
+				DB:         "testDB",
+				Collection: "clustered",
+				ClusteredIndex: &ClusteredIndex{
+					Key:  bson.D{{Key: "_id", Value: int32(1)}},
+					Name: "myClusteredIndex",
+				},
+			},
+			{
+				DB:         "testDB",
+				Collection: "system.buckets.weather",
+				ClusteredIndex: &ClusteredIndex{
+					Key: bson.D{{Key: "_id", Value: int32(1)}},
+				},
+			},
+		},
+		details,
+		"should report only clustered collections",
+	)
+}
+
+func TestCollectionDetailsCollation(t *testing.T) {
+	collation := bson.D{
+		{Key: "locale", Value: "fr"},
+		{Key: "strength", Value: int32(1)},
+	}
+
+	details := getCollectionDetails([]bson.D{
+		makeMetadataDocWithOptions("testDB", "plain", bson.D{}),
+		makeMetadataDocWithOptions("testDB", "french", bson.D{
+			{Key: "collation", Value: collation},
+		}),
+	}, nil)
+
+	assert.Equal(
+		t,
+		[]CollectionDetails{
+			{DB: "testDB", Collection: "french", Collation: collation},
+		},
+		details,
+		"should report only collections with a collation",
+	)
+}
+
+func TestCollectionDetailsHiddenIndexes(t *testing.T) {
+	details := getCollectionDetails([]bson.D{
+		makeMetadataDocWithIndexes("testDB", "testColl", bson.D{}, bson.A{
+			bson.D{{Key: "key", Value: bson.D{{Key: "_id", Value: 1}}}, {Key: "name", Value: "_id_"}},
+			bson.D{{Key: "key", Value: bson.D{{Key: "a", Value: 1}}}, {Key: "name", Value: "a_1"}, {Key: "hidden", Value: true}},
+			bson.D{{Key: "key", Value: bson.D{{Key: "b", Value: 1}}}, {Key: "name", Value: "b_1"}, {Key: "hidden", Value: false}},
+		}),
+	}, nil)
+
+	assert.Equal(
+		t,
+		[]CollectionDetails{
+			{DB: "testDB", Collection: "testColl", HiddenIndexes: []string{"a_1"}},
+		},
+		details,
+		"should report hidden indexes",
+	)
+}
+
+func TestCollectionDetailsSpecialIndexOptions(t *testing.T) {
+	details := getCollectionDetails([]bson.D{
+		makeMetadataDocWithIndexes("testDB", "places", bson.D{}, bson.A{
+			bson.D{{Key: "key", Value: bson.D{{Key: "_id", Value: 1}}}, {Key: "name", Value: "_id_"}},
+			bson.D{
+				{Key: "key", Value: bson.D{{Key: "loc", Value: "2dsphere"}}},
+				{Key: "name", Value: "loc_2dsphere"},
+				{Key: "2dsphereIndexVersion", Value: int32(3)},
+			},
+			bson.D{
+				{Key: "key", Value: bson.D{{Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: 1}}},
+				{Key: "name", Value: "desc_text"},
+				{Key: "weights", Value: bson.D{{Key: "desc", Value: int32(1)}}},
+				{Key: "default_language", Value: "english"},
+				{Key: "language_override", Value: "language"},
+				{Key: "textIndexVersion", Value: int32(3)},
+			},
+		}),
+		makeMetadataDocWithIndexes("testDB", "plain", bson.D{}, bson.A{
+			bson.D{{Key: "key", Value: bson.D{{Key: "a", Value: 1}}}, {Key: "name", Value: "a_1"}, {Key: "unique", Value: true}},
+		}),
+	}, nil)
+
+	assert.Equal(
+		t,
+		[]CollectionDetails{
+			{
+				DB:         "testDB",
+				Collection: "places",
+				SpecialIndexOptions: []SpecialIndexOptions{
+					{Name: "loc_2dsphere", Options: bson.D{{Key: "2dsphereIndexVersion", Value: int32(3)}}},
+					{
+						Name: "desc_text",
+						Options: bson.D{
+							{Key: "weights", Value: bson.D{{Key: "desc", Value: int32(1)}}},
+							{Key: "default_language", Value: "english"},
+							{Key: "language_override", Value: "language"},
+							{Key: "textIndexVersion", Value: int32(3)},
+						},
+					},
+				},
+			},
+		},
+		details,
+		"should report only geospatial & text options",
+	)
+}
+
+func TestCollectionDetailsWildcardIndexes(t *testing.T) {
+	projection := bson.D{{Key: "secret", Value: int32(0)}}
+
+	details := getCollectionDetails([]bson.D{
+		makeMetadataDocWithIndexes("testDB", "flexible", bson.D{}, bson.A{
+			bson.D{{Key: "key", Value: bson.D{{Key: "_id", Value: 1}}}, {Key: "name", Value: "_id_"}},
+			bson.D{
+				{Key: "key", Value: bson.D{{Key: "$**", Value: int32(1)}}},
+				{Key: "name", Value: "$**_1"},
+				{Key: "wildcardProjection", Value: projection},
+			},
+			bson.D{
+				{Key: "key", Value: bson.D{{Key: "tenant", Value: int32(1)}, {Key: "attrs.$**", Value: int32(1)}}},
+				{Key: "name", Value: "tenant_1_attrs.$**_1"},
+			},
+		}),
+		makeMetadataDocWithIndexes("testDB", "plain", bson.D{}, bson.A{
+			bson.D{{Key: "key", Value: bson.D{{Key: "a$**", Value: 1}}}, {Key: "name", Value: "a$**_1"}},
+		}),
+	}, nil)
+
+	assert.Equal(
+		t,
+		[]CollectionDetails{
+			{
+				DB:         "testDB",
+				Collection: "flexible",
+				WildcardIndexes: []WildcardIndex{
+					{Name: "$**_1", Key: bson.D{{Key: "$**", Value: int32(1)}}, WildcardProjection: projection},
+					{
+						Name: "tenant_1_attrs.$**_1",
+						Key:  bson.D{{Key: "tenant", Value: int32(1)}, {Key: "attrs.$**", Value: int32(1)}},
+					},
+				},
+			},
+		},
+		details,
+		"should report only wildcard indexes",
+	)
+}
+
+func TestCollectionDetailsShardKey(t *testing.T) {
+	details := getCollectionDetails(
+		[]bson.D{
+			makeMetadataDocWithOptions("testDB", "fromOptions", bson.D{
+				{Key: "shardKey", Value: bson.D{{Key: "a", Value: int32(1)}}},
+			}),
+			makeMetadataDoc("testDB", "fromConfig"),
+			makeMetadataDoc("testDB", "unsharded"),
+		},
+		map[string]bson.D{
+			"testDB.fromConfig": {{Key: "b", Value: "hashed"}},
+		},
+	)
+
+	assert.Equal(
+		t,
+		[]CollectionDetails{
+			{DB: "testDB", Collection: "fromOptions", ShardKey: bson.D{{Key: "a", Value: int32(1)}}},
+			{DB: "testDB", Collection: "fromConfig", ShardKey: bson.D{{Key: "b", Value: "hashed"}}},
+		},
+		details,
+		"should report shard keys from metadata or config.collections",
+	)
+}
+
+func TestCollectionDetailsStorageEngine(t *testing.T) {
+	storageEngine := bson.D{
+		{Key: "wiredTiger", Value: bson.D{
+			{Key: "configString", Value: "block_compressor=zstd"},
+		}},
+	}
+
+	details := getCollectionDetails([]bson.D{
+		makeMetadataDoc("testDB", "plain"),
+		makeMetadataDocWithOptions("testDB", "empty", bson.D{{Key: "storageEngine", Value: bson.D{}}}),
+		makeMetadataDocWithOptions("testDB", "zstd", bson.D{{Key: "storageEngine", Value: storageEngine}}),
+	}, nil)
+
+	assert.Equal(
+		t,
+		[]CollectionDetails{
+			{DB: "testDB", Collection: "zstd", StorageEngine: storageEngine},
+		},
+		details,
+		"should report only non-empty storage engine options",
+	)
+}
+
+func TestCollectionDetailsIDIndex(t *testing.T) {
+	idIndexSpec := bson.D{
+		{Key: "v", Value: int32(2)},
+		{Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}},
+		{Key: "name", Value: "_id_"},
+	}
+	noAutoIndex := bson.D{{Key: "autoIndexId", Value: false}}
+
+	details := getCollectionDetails([]bson.D{
+		makeMetadataDoc("testDB", "plain"),
+		makeMetadataDocWithOptions("testDB", "noAutoIndex", noAutoIndex),
+		makeMetadataDocWithIndexes("testDB", "noAutoIndexButIndexed", noAutoIndex, bson.A{idIndexSpec}),
+		makeMetadataDocWithOptions("testDB", "autoIndex", bson.D{{Key: "autoIndexId", Value: true}}),
+		makeMetadataDocWithOptions("testDB", "explicit", bson.D{{Key: "idIndex", Value: idIndexSpec}}),
+	}, nil)
+
+	autoIndexOff, autoIndexOn := false, true
+
+	assert.Equal(
+		t,
+		[]CollectionDetails{
+			{DB: "testDB", Collection: "noAutoIndex", AutoIndexID: &autoIndexOff, NoIDIndex: true},
+			{DB: "testDB", Collection: "noAutoIndexButIndexed", AutoIndexID: &autoIndexOff},
+			{DB: "testDB", Collection: "autoIndex", AutoIndexID: &autoIndexOn},
+			{DB: "testDB", Collection: "explicit", IDIndex: idIndexSpec},
+		},
+		details,
+		"should report autoIndexId & idIndex",
+	)
+}
+
+func TestCollectionDetailsLegacyFlags(t *testing.T) {
+	details := getCollectionDetails([]bson.D{
+		makeMetadataDoc("testDB", "plain"),
+		makeMetadataDocWithOptions("testDB", "powerOf2", bson.D{{Key: "flags", Value: int32(1)}}),
+		makeMetadataDocWithOptions("testDB", "both", bson.D{{Key: "flags", Value: int64(3)}}),
+		makeMetadataDocWithOptions("testDB", "none", bson.D{{Key: "flags", Value: 0.0}}),
+		makeMetadataDocWithOptions("testDB", "unknown", bson.D{{Key: "flags", Value: int32(6)}}),
+		makeMetadataDocWithOptions("testDB", "bogus", bson.D{{Key: "flags", Value: "1"}}),
+	}, nil)
+
+	assert.Equal(
+		t,
+		[]CollectionDetails{
+			{DB: "testDB", Collection: "powerOf2", LegacyFlags: &LegacyFlags{Value: 1, UsePowerOf2Sizes: true}},
+			{DB: "testDB", Collection: "both", LegacyFlags: &LegacyFlags{Value: 3, UsePowerOf2Sizes: true, NoPadding: true}},
+			{DB: "testDB", Collection: "none", LegacyFlags: &LegacyFlags{}},
+			{DB: "testDB", Collection: "unknown", LegacyFlags: &LegacyFlags{Value: 6, NoPadding: true, UnknownBits: 4}},
+		},
+		details,
+		"should decode numeric legacy flags",
+	)
+}
+
+func TestCollectionDetailsQueryableEncryption(t *testing.T) {
+	encryptedFields := bson.D{{Key: "fields", Value: bson.A{
+		bson.D{
+			{Key: "path", Value: "ssn"},
+			{Key: "bsonType", Value: "string"},
+			{Key: "queries", Value: bson.D{{Key: "queryType", Value: "equality"}}},
+		},
+	}}}
+	renamedFields := append(
+		bson.D{{Key: "escCollection", Value: "patientsState.esc"}},
+		encryptedFields...,
+	)
+
+	mdDocs := []bson.D{
+		makeMetadataDocWithOptions("testDB", "patients", bson.D{{Key: "encryptedFields", Value: encryptedFields}}),
+		makeMetadataDocWithOptions("testDB", "enxcol_.patients.esc", bson.D{{Key: "clusteredIndex", Value: true}}),
+		makeMetadataDocWithOptions("testDB", "enxcol_.patients.ecoc", bson.D{}),
+		makeMetadataDocWithOptions("testDB", "renamed", bson.D{{Key: "encryptedFields", Value: renamedFields}}),
+		makeMetadataDocWithOptions("testDB", "patientsState.esc", bson.D{}),
+		makeMetadataDocWithOptions("otherDB", "enxcol_.renamed.ecoc", bson.D{}),
+		makeMetadataDocWithOptions("testDB", "plain", bson.D{}),
+	}
+
+	assert.Equal(
+		t,
+		[]CollectionDetails{
+			{
+				DB:                         "testDB",
+				Collection:                 "patients",
+				EncryptedFields:            encryptedFields,
+				EncryptionStateCollections: []string{"enxcol_.patients.esc", "enxcol_.patients.ecoc"},
+			},
+			{
+				DB:                  "testDB",
+				Collection:          "enxcol_.patients.esc",
+				ClusteredIndex:      &ClusteredIndex{Key: bson.D{{Key: "_id", Value: int32(1)}}},
+				EncryptedCollection: "patients",
+			},
+			{DB: "testDB", Collection: "enxcol_.patients.ecoc", EncryptedCollection: "patients"},
+			{
+				DB:                         "testDB",
+				Collection:                 "renamed",
+				EncryptedFields:            renamedFields,
+				EncryptionStateCollections: []string{"patientsState.esc"},
+			},
+			{DB: "testDB", Collection: "patientsState.esc", EncryptedCollection: "renamed"},
+		},
+		getCollectionDetails(mdDocs, nil),
+		"should report encrypted fields and link state collections in the same database",
+	)
+}
+
+func TestShardKeyCollector(t *testing.T) {
+	header := archive.NamespaceHeader{Database: "config", Collection: "collections"}
+
+	collector := newShardKeyCollector(true)
+	assert.Positive(t, collector.docLimit(header), "should read config.collections")
+	assert.Zero(
+		t,
+		collector.docLimit(archive.NamespaceHeader{Database: "testDB", Collection: "collections"}),
+		"should ignore other namespaces",
+	)
+
+	collector.analyze(header, bson.D{
+		{Key: "_id", Value: "testDB.sharded"},
+		{Key: "key", Value: bson.D{{Key: "a", Value: int32(1)}}},
+	})
+	collector.analyze(header, bson.D{
+		{Key: "_id", Value: "testDB.dropped"},
+		{Key: "key", Value: bson.D{{Key: "a", Value: int32(1)}}},
+		{Key: "dropped", Value: true},
+	})
+
+	assert.Equal(
+		t,
+		map[string]bson.D{"testDB.sharded": {{Key: "a", Value: int32(1)}}},
+		collector.keys,
+		"should collect shard keys of undropped collections",
+	)
+}
+
+func TestCollectionDetailsValidation(t *testing.T) {
+	validator := bson.D{{Key: "$jsonSchema", Value: bson.D{{Key: "required", Value: bson.A{"name"}}}}}
+
+	details := getCollectionDetails([]bson.D{
+		makeMetadataDocWithOptions("testDB", "validated", bson.D{
+			{Key: "validator", Value: validator},
+			{Key: "validationLevel", Value: "moderate"},
+			{Key: "validationAction", Value: "warn"},
+		}),
+		makeMetadataDocWithOptions("testDB", "unvalidated", bson.D{}),
+	}, nil)
+
+	assert.Equal(
+		t,
+		[]CollectionDetails{
+			{
+				DB:               "testDB",
+				Collection:       "validated",
+				Validator:        validator,
+				ValidationLevel:  "moderate",
+				ValidationAction: "warn",
+			},
+		},
+		details,
+		"should report validation options",
+	)
+}
+
+func TestCollectionDetailsTimeSeries(t *testing.T) {
+	mdDocs := []bson.D{
+		makeMetadataDocWithOptions("testDB", "weather", bson.D{
+			{Key: "timeseries", Value: bson.D{{Key: "timeField", Value: "ts"}}},
+		}),
+		makeMetadataDocWithOptions("testDB", "system.buckets.weather", bson.D{}),
+		makeMetadataDocWithOptions("testDB", "system.buckets.orphan", bson.D{}),
+		makeMetadataDocWithOptions("otherDB", "weather", bson.D{}),
+	}
+
+	assert.Equal(
+		t,
+		[]CollectionDetails{
+			{DB: "testDB", Collection: "weather", TimeSeriesBuckets: "system.buckets.weather"},
+			{DB: "testDB", Collection: "system.buckets.weather", TimeSeriesView: "weather"},
+		},
+		getCollectionDetails(mdDocs, nil),
+		"should link only buckets & views in the same database",
+	)
+
+	assert.Equal(t, 1, getSummary(mdDocs, nil, nil).TimeSeries, "should count time-series collections")
+}
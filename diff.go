@@ -0,0 +1,217 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v3"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// MetadataDiff lists how two archives’ namespaces differ. Each list holds
+// namespaces (DB.COLLECTION) in sorted order.
+type MetadataDiff struct {
+	// Added & Removed are the namespaces that only the new or only the
+	// old archive has.
+	Added   []string `bson:"added"`
+	Removed []string `bson:"removed"`
+
+	// Changed are the namespaces whose collection metadata differs.
+	Changed []string `bson:"changed"`
+}
+
+// runDiff implements the diff subcommand.
+func runDiff(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 2 {
+		return fmt.Errorf("expected 2 archive files to compare, not %d", cmd.Args().Len())
+	}
+
+	parseOpts, warnOut, err := getParseOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	var reports [2]Report
+
+	for i, path := range cmd.Args().Slice() {
+		file, err := os.Open(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to open %#q", path)
+		}
+
+		reports[i], err = getReport(newContextReader(ctx, file), warnOut, parseOpts)
+		_ = file.Close()
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse %#q", path)
+		}
+	}
+
+	diff, err := getMetadataDiff(reports[0].CollectionMetadata, reports[1].CollectionMetadata, cmd.Bool("diff-ignore-order"))
+	if err != nil {
+		return err
+	}
+
+	return writeMetadataDiff(os.Stdout, diff, cmd)
+}
+
+// writeMetadataDiff writes diff as Extended JSON per the command’s output
+// flags.
+func writeMetadataDiff(out io.Writer, diff MetadataDiff, cmd *cli.Command) error {
+	json, err := marshalExtJSON(diff, outputOptions{
+		pretty:     cmd.Bool("pretty"),
+		indent:     int(cmd.Int("indent")),
+		escapeHTML: cmd.Bool("escape-html"),
+		canonical:  cmd.Bool("canonical"),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode diff")
+	}
+
+	_, err = out.Write(json)
+
+	return errors.Wrap(err, "failed to output diff")
+}
+
+// readBaselineMetadata returns the collection metadata from a report that
+// an earlier run saved as JSON.
+func readBaselineMetadata(path string) ([]bson.D, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read baseline report %#q", path)
+	}
+
+	var baseline struct {
+		CollectionMetadata []bson.D `bson:"collectionMetadata"`
+	}
+
+	err = bson.UnmarshalExtJSON(content, false, &baseline)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse baseline report %#q", path)
+	}
+
+	if baseline.CollectionMetadata == nil {
+		return nil, fmt.Errorf("baseline report %#q has no collectionMetadata", path)
+	}
+
+	return baseline.CollectionMetadata, nil
+}
+
+// getBaselineDiff compares a baseline report’s collection metadata with
+// the current report’s. The baseline may have been saved as relaxed
+// Extended JSON, which doesn’t preserve numeric types, so both sides go
+// through that encoding before the comparison.
+func getBaselineDiff(baseline, current []bson.D) (MetadataDiff, error) {
+	var relaxed [2][]bson.D
+
+	for i, docs := range [][]bson.D{baseline, current} {
+		json, err := bson.MarshalExtJSON(bson.D{{Key: "docs", Value: docs}}, false, false)
+		if err != nil {
+			return MetadataDiff{}, errors.Wrap(err, "failed to encode collection metadata")
+		}
+
+		var decoded struct {
+			Docs []bson.D `bson:"docs"`
+		}
+
+		err = bson.UnmarshalExtJSON(json, false, &decoded)
+		if err != nil {
+			return MetadataDiff{}, errors.Wrap(err, "failed to decode collection metadata")
+		}
+
+		relaxed[i] = decoded.Docs
+	}
+
+	return getMetadataDiff(relaxed[0], relaxed[1], false)
+}
+
+// getMetadataDiff compares two archives’ collection metadata. If
+// ignoreOrder is set, documents that differ only in field order compare
+// equal; see sortDocumentKeys.
+func getMetadataDiff(oldDocs, newDocs []bson.D, ignoreOrder bool) (MetadataDiff, error) {
+	diff := MetadataDiff{Added: []string{}, Removed: []string{}, Changed: []string{}}
+
+	oldByNS := map[string]bson.D{}
+	for _, mdDoc := range oldDocs {
+		db, coll := getNamespace(mdDoc)
+		oldByNS[db+"."+coll] = mdDoc
+	}
+
+	for _, newDoc := range newDocs {
+		db, coll := getNamespace(newDoc)
+		ns := db + "." + coll
+
+		oldDoc, ok := oldByNS[ns]
+		if !ok {
+			diff.Added = append(diff.Added, ns)
+			continue
+		}
+
+		delete(oldByNS, ns)
+
+		if ignoreOrder {
+			oldDoc, newDoc = sortDocumentKeys(oldDoc), sortDocumentKeys(newDoc)
+		}
+
+		equal, err := bsonutil.IsEqual(oldDoc, newDoc)
+		if err != nil {
+			return MetadataDiff{}, errors.Wrapf(err, "failed to compare %s metadata", ns)
+		}
+
+		if !equal {
+			diff.Changed = append(diff.Changed, ns)
+		}
+	}
+
+	for ns := range oldByNS {
+		diff.Removed = append(diff.Removed, ns)
+	}
+
+	slices.Sort(diff.Added)
+	slices.Sort(diff.Removed)
+	slices.Sort(diff.Changed)
+
+	return diff, nil
+}
+
+// sortDocumentKeys returns a copy of doc with its fields, and those of
+// any nested documents, sorted by name. Index key patterns (i.e., “key”
+// fields) keep their order, which determines the index.
+func sortDocumentKeys(doc bson.D) bson.D {
+	sorted := make(bson.D, len(doc))
+
+	for i, elem := range doc {
+		if elem.Key != "key" {
+			elem.Value = sortValueKeys(elem.Value)
+		}
+
+		sorted[i] = elem
+	}
+
+	slices.SortStableFunc(sorted, func(a, b bson.E) int {
+		return cmp.Compare(a.Key, b.Key)
+	})
+
+	return sorted
+}
+
+func sortValueKeys(value any) any {
+	switch v := value.(type) {
+	case bson.D:
+		return sortDocumentKeys(v)
+	case bson.A:
+		sorted := make(bson.A, len(v))
+		for i, elem := range v {
+			sorted[i] = sortValueKeys(elem)
+		}
+
+		return sorted
+	default:
+		return value
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMetadataDiff(t *testing.T) {
+	index := bson.D{
+		{Key: "name", Value: "a_1_b_1"},
+		{Key: "key", Value: bson.D{{Key: "a", Value: 1}, {Key: "b", Value: 1}}},
+	}
+	reorderedIndex := bson.D{
+		{Key: "key", Value: bson.D{{Key: "a", Value: 1}, {Key: "b", Value: 1}}},
+		{Key: "name", Value: "a_1_b_1"},
+	}
+	reversedIndex := bson.D{
+		{Key: "name", Value: "a_1_b_1"},
+		{Key: "key", Value: bson.D{{Key: "b", Value: 1}, {Key: "a", Value: 1}}},
+	}
+	options := bson.D{{Key: "capped", Value: true}, {Key: "size", Value: 100}}
+	reorderedOptions := bson.D{{Key: "size", Value: 100}, {Key: "capped", Value: true}}
+
+	oldDocs := []bson.D{
+		makeMetadataDocWithIndexes("db", "reordered", options, bson.A{index}),
+		makeMetadataDocWithIndexes("db", "reversed", bson.D{}, bson.A{index}),
+		makeMetadataDoc("db", "removed"),
+	}
+	newDocs := []bson.D{
+		makeMetadataDocWithIndexes("db", "reordered", reorderedOptions, bson.A{reorderedIndex}),
+		makeMetadataDocWithIndexes("db", "reversed", bson.D{}, bson.A{reversedIndex}),
+		makeMetadataDoc("db", "added"),
+	}
+
+	diff, err := getMetadataDiff(oldDocs, newDocs, false)
+	require.NoError(t, err, "should compare metadata")
+	assert.Equal(
+		t,
+		MetadataDiff{Added: []string{"db.added"}, Removed: []string{"db.removed"}, Changed: []string{"db.reordered", "db.reversed"}},
+		diff,
+		"should treat reordered fields as changes",
+	)
+
+	diff, err = getMetadataDiff(oldDocs, newDocs, true)
+	require.NoError(t, err, "should compare metadata")
+	assert.Equal(t, []string{"db.reversed"}, diff.Changed, "should ignore field order, except in index keys")
+
+	assert.Equal(t, index, oldDocs[0][2].Value.(bson.D)[1].Value.(bson.A)[0], "should not modify metadata")
+}
+
+func TestBaselineDiff(t *testing.T) {
+	options := bson.D{{Key: "capped", Value: true}, {Key: "size", Value: int64(4096)}}
+	baselineDocs := []bson.D{
+		makeMetadataDocWithOptions("db", "capped", options),
+		makeMetadataDocWithOptions("db", "indexed", bson.D{}),
+		makeMetadataDoc("db", "removed"),
+	}
+	currentDocs := []bson.D{
+		makeMetadataDocWithOptions("db", "capped", options),
+		makeMetadataDocWithIndexes("db", "indexed", bson.D{}, bson.A{bson.D{{Key: "name", Value: "a_1"}}}),
+		makeMetadataDoc("db", "added"),
+	}
+
+	for _, canonical := range []bool{false, true} {
+		json, err := Report{CollectionMetadata: baselineDocs}.MarshalExtJSON(canonical, false)
+		require.NoError(t, err, "should encode baseline")
+
+		path := filepath.Join(t.TempDir(), "baseline.json")
+		require.NoError(t, os.WriteFile(path, json, 0o644), "should write baseline")
+
+		baseline, err := readBaselineMetadata(path)
+		require.NoError(t, err, "should read baseline")
+
+		diff, err := getBaselineDiff(baseline, currentDocs)
+		require.NoError(t, err, "should compare metadata")
+		assert.Equal(
+			t,
+			MetadataDiff{Added: []string{"db.added"}, Removed: []string{"db.removed"}, Changed: []string{"db.indexed"}},
+			diff,
+			"should compare against baseline (canonical: %t)", canonical,
+		)
+	}
+
+	path := filepath.Join(t.TempDir(), "empty.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"header": {}}`), 0o644))
+	_, err := readBaselineMetadata(path)
+	assert.Error(t, err, "should require collectionMetadata")
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// These let callers distinguish failure categories via errors.Is.
+var (
+	// ErrBadMagic indicates that the input doesn’t start with the
+	// mongodump archive magic number.
+	ErrBadMagic = errors.New("bad archive magic number")
+
+	// ErrTruncated indicates that the input ended prematurely.
+	ErrTruncated = errors.New("archive is truncated")
+
+	// ErrBadHeader indicates that the archive header is unreadable.
+	ErrBadHeader = errors.New("bad archive header")
+
+	// ErrMetadataParse indicates that a collection metadata document
+	// is unreadable or malformed.
+	ErrMetadataParse = errors.New("bad collection metadata")
+
+	// ErrDocumentTooLarge indicates that a body document exceeds the
+	// maximum document size.
+	ErrDocumentTooLarge = errors.New("document is too large")
+
+	// ErrCorrupt indicates that the archive’s structure is invalid.
+	ErrCorrupt = errors.New("archive is corrupt")
+
+	// ErrStrict indicates an anomaly that is only a warning unless
+	// ParseOptions.Strict is set.
+	ErrStrict = errors.New("strict mode forbids this")
+
+	// ErrEmpty indicates that an archive contains nothing, which
+	// --fail-on-empty treats as a failure.
+	ErrEmpty = errors.New("archive is empty")
+
+	// ErrUnexpectedNamespaces indicates that an archive’s namespaces
+	// don’t match those that --expect-namespaces lists.
+	ErrUnexpectedNamespaces = errors.New("archive namespaces are not as expected")
+
+	// ErrTimeout indicates that parsing took longer than --timeout.
+	ErrTimeout = errors.New("timed out")
+
+	// ErrCRCMismatch indicates that a namespace’s documents don’t match
+	// the CRC that the archive records for them.
+	ErrCRCMismatch = errors.New("CRC mismatch")
+)
+
+// Exit statuses for the CLI. Parse failures map to these via exitCode.
+const (
+	exitFailure     = 1
+	exitBadMagic    = 2
+	exitCorrupt     = 3
+	exitCRCMismatch = 4
+	exitTimeout     = 5
+)
+
+// exitCode returns the CLI’s exit status for the given error.
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, ErrTimeout):
+		return exitTimeout
+	case errors.Is(err, ErrCRCMismatch):
+		return exitCRCMismatch
+	case errors.Is(err, ErrBadMagic):
+		return exitBadMagic
+	case errors.Is(err, ErrTruncated),
+		errors.Is(err, ErrBadHeader),
+		errors.Is(err, ErrMetadataParse),
+		errors.Is(err, ErrDocumentTooLarge),
+		errors.Is(err, ErrCorrupt):
+		return exitCorrupt
+	default:
+		return exitFailure
+	}
+}
+
+// markedError associates an error with one of the sentinels above
+// without altering the error’s message.
+type markedError struct {
+	err      error
+	sentinel error
+}
+
+func markError(err, sentinel error) error {
+	return &markedError{err: err, sentinel: sentinel}
+}
+
+func (me *markedError) Error() string {
+	return me.err.Error()
+}
+
+func (me *markedError) Unwrap() error {
+	return me.err
+}
+
+func (me *markedError) Is(target error) bool {
+	return target == me.sentinel
+}
+
+// markIfTruncated marks err with ErrTruncated if it stems from EOF.
+func markIfTruncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return markError(err, ErrTruncated)
+	}
+
+	return err
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// readNamespaceManifest reads a newline-delimited list of namespaces
+// (“db.collection”). Blank lines and lines starting with “#” are ignored.
+func readNamespaceManifest(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read namespace manifest %#q", path)
+	}
+
+	var namespaces []string
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if !strings.Contains(line, ".") {
+			return nil, fmt.Errorf("namespace manifest %#q has %#q, which is not of the form DB.COLLECTION", path, line)
+		}
+
+		namespaces = append(namespaces, line)
+	}
+
+	return namespaces, nil
+}
+
+// checkExpectedNamespaces returns an ErrUnexpectedNamespaces error if any
+// expected namespace is absent from mdDocs or, if exact is true, if mdDocs
+// has any namespace that isn’t expected.
+func checkExpectedNamespaces(mdDocs []bson.D, expected []string, exact bool) error {
+	var actual []string
+
+	for _, mdDoc := range mdDocs {
+		db, coll := getNamespace(mdDoc)
+		actual = append(actual, db+"."+coll)
+	}
+
+	var problems []string
+
+	missing := slices.DeleteFunc(slices.Clone(expected), func(ns string) bool {
+		return slices.Contains(actual, ns)
+	})
+	if len(missing) > 0 {
+		problems = append(problems, fmt.Sprintf("lacks expected namespaces %v", missing))
+	}
+
+	if exact {
+		extra := slices.DeleteFunc(actual, func(ns string) bool {
+			return slices.Contains(expected, ns)
+		})
+		if len(extra) > 0 {
+			problems = append(problems, fmt.Sprintf("has unexpected namespaces %v", extra))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return markError(
+		fmt.Errorf("archive %s", strings.Join(problems, " and ")),
+		ErrUnexpectedNamespaces,
+	)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestReadNamespaceManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest")
+	require.NoError(t, os.WriteFile(path, []byte("# nightly\ndb.a\n\n  db.b  \r\n"), 0o644), "should write manifest")
+
+	namespaces, err := readNamespaceManifest(path)
+	require.NoError(t, err, "should read manifest")
+	assert.Equal(t, []string{"db.a", "db.b"}, namespaces, "should skip blanks & comments")
+
+	require.NoError(t, os.WriteFile(path, []byte("db.a\nnodot\n"), 0o644), "should write manifest")
+	_, err = readNamespaceManifest(path)
+	assert.ErrorContains(t, err, "nodot", "should reject malformed namespace")
+
+	_, err = readNamespaceManifest(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err, "should fail on missing file")
+}
+
+func TestCheckExpectedNamespaces(t *testing.T) {
+	mdDocs := []bson.D{makeMetadataDoc("db", "a"), makeMetadataDoc("db", "b"), makeMetadataDoc("db", "c")}
+
+	assert.NoError(t, checkExpectedNamespaces(mdDocs, []string{"db.a", "db.c"}, false), "should allow extras")
+
+	err := checkExpectedNamespaces(mdDocs, []string{"db.a", "db.z", "db.y"}, false)
+	assert.ErrorIs(t, err, ErrUnexpectedNamespaces, "should fail on missing namespaces")
+	assert.EqualError(t, err, "archive lacks expected namespaces [db.z db.y]", "should list missing namespaces")
+
+	err = checkExpectedNamespaces(mdDocs, []string{"db.a", "db.z"}, true)
+	assert.EqualError(
+		t,
+		err,
+		"archive lacks expected namespaces [db.z] and has unexpected namespaces [db.b db.c]",
+		"should list missing & extra namespaces",
+	)
+
+	assert.NoError(t, checkExpectedNamespaces(mdDocs, []string{"db.c", "db.b", "db.a"}, true), "should match exactly")
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"math"
+
+	"github.com/mongodb/mongo-tools/common/archive"
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// fcvDocumentID is the _id of the admin.system.version document that
+// records the feature compatibility version.
+const fcvDocumentID = "featureCompatibilityVersion"
+
+// fcvCollector finds the feature compatibility version document among
+// the archive’s admin.system.version documents. That collection is tiny,
+// so this is always enabled when the body is read.
+type fcvCollector struct {
+	doc bson.D
+}
+
+func (fc *fcvCollector) docLimit(header archive.NamespaceHeader) int {
+	if header.Database == "admin" && header.Collection == "system.version" {
+		return math.MaxInt
+	}
+
+	return 0
+}
+
+func (fc *fcvCollector) analyze(_ archive.NamespaceHeader, doc bson.D) {
+	if id, _ := bsonutil.FindStringValueByKey("_id", &doc); id == fcvDocumentID {
+		fc.doc = doc
+	}
+}
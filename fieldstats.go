@@ -0,0 +1,83 @@
+package main
+
+import (
+	"github.com/mongodb/mongo-tools/common/archive"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// fieldStatsCounter tallies how often each top-level field appears in
+// each namespace’s first documents.
+type fieldStatsCounter struct {
+	limit int
+	stats map[string]*fieldTally
+}
+
+// fieldTally counts a namespace’s examined documents and how many of them
+// have each top-level field. Both fieldStatsCounter and schemaInferrer
+// keep one per namespace.
+type fieldTally struct {
+	docCount int
+
+	// fields lists the fields in order of first appearance.
+	fields      []string
+	fieldCounts map[string]int
+}
+
+func newFieldTally() *fieldTally {
+	return &fieldTally{fieldCounts: map[string]int{}}
+}
+
+// add tallies doc’s top-level fields.
+func (ft *fieldTally) add(doc bson.D) {
+	ft.docCount++
+
+	for _, elem := range doc {
+		if _, seen := ft.fieldCounts[elem.Key]; !seen {
+			ft.fields = append(ft.fields, elem.Key)
+		}
+		ft.fieldCounts[elem.Key]++
+	}
+}
+
+func newFieldStatsCounter(limit int) *fieldStatsCounter {
+	return &fieldStatsCounter{
+		limit: limit,
+		stats: map[string]*fieldTally{},
+	}
+}
+
+func (fsc *fieldStatsCounter) docLimit(archive.NamespaceHeader) int {
+	return fsc.limit
+}
+
+func (fsc *fieldStatsCounter) analyze(header archive.NamespaceHeader, doc bson.D) {
+	ns := header.Database + "." + header.Collection
+
+	stats, ok := fsc.stats[ns]
+	if !ok {
+		stats = newFieldTally()
+		fsc.stats[ns] = stats
+	}
+
+	stats.add(doc)
+}
+
+// results returns each namespace’s examined document count & field
+// frequencies, keyed by namespace. Fields are in order of appearance.
+func (fsc *fieldStatsCounter) results() map[string]bson.D {
+	results := map[string]bson.D{}
+
+	for ns, stats := range fsc.stats {
+		fields := bson.D{}
+		for _, field := range stats.fields {
+			fields = append(fields, bson.E{Key: field, Value: stats.fieldCounts[field]})
+		}
+
+		results[ns] = bson.D{
+			{Key: "documents", Value: stats.docCount},
+			{Key: "fields", Value: fields},
+		}
+	}
+
+	return results
+}
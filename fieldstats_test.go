@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mongodb/mongo-tools/common/archive"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestFieldStatsCounter(t *testing.T) {
+	header := archive.NamespaceHeader{Database: "testDB", Collection: "testColl"}
+
+	counter := newFieldStatsCounter(10)
+	counter.analyze(header, bson.D{{Key: "a", Value: 1}, {Key: "b", Value: 1}})
+	counter.analyze(header, bson.D{{Key: "a", Value: 1}, {Key: "c", Value: 1}})
+	counter.analyze(header, bson.D{{Key: "a", Value: 1}})
+
+	assert.Equal(
+		t,
+		map[string]bson.D{
+			"testDB.testColl": {
+				{Key: "documents", Value: 3},
+				{Key: "fields", Value: bson.D{
+					{Key: "a", Value: 3},
+					{Key: "b", Value: 1},
+					{Key: "c", Value: 1},
+				}},
+			},
+		},
+		counter.results(),
+		"should count each field’s appearances",
+	)
+}
@@ -0,0 +1,532 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	encjson "encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	formatJSON    = "json"
+	formatCSV     = "csv"
+	formatTable   = "table"
+	formatBSON    = "bson"
+	formatIndexes = "indexes"
+	formatNDJSON  = "ndjson"
+)
+
+var formats = []string{formatJSON, formatCSV, formatTable, formatBSON, formatIndexes, formatNDJSON}
+
+func validateFormat(format string) error {
+	if !slices.Contains(formats, format) {
+		return fmt.Errorf("format must be one of %v, not %#q", formats, format)
+	}
+
+	return nil
+}
+
+// outputOptions control how the report is rendered.
+type outputOptions struct {
+	format string
+
+	// These apply only to JSON output.
+	pretty         bool
+	prettyMetadata bool
+	indent         int
+	escapeHTML     bool
+	canonical      bool
+
+	// color applies only to tables.
+	color bool
+}
+
+func validateIndent(indent int64) error {
+	if indent < 0 {
+		return fmt.Errorf("indent must be non-negative, not %d", indent)
+	}
+
+	return nil
+}
+
+// writeReport renders the report to out.
+func writeReport(out io.Writer, report Report, opts outputOptions) error {
+	switch opts.format {
+	case formatCSV:
+		return writeCSV(out, report)
+	case formatTable:
+		return writeTable(out, report, opts.color)
+	case formatBSON:
+		return writeBSON(out, report)
+	case formatIndexes:
+		return writeIndexes(out, report, opts)
+	case formatNDJSON:
+		return writeNDJSON(out, report, opts)
+	default:
+		return writeJSON(out, report, opts)
+	}
+}
+
+// defaultIndent is how many spaces pretty-printed JSON indents by default.
+const defaultIndent = 2
+
+// These are the values of Report.ExtJSONMode.
+const (
+	extJSONRelaxed   = "relaxed"
+	extJSONCanonical = "canonical"
+)
+
+// MarshalExtJSON encodes the report as Extended JSON. With canonical and
+// pretty both false this is identical to the CLI’s default output.
+func (r Report) MarshalExtJSON(canonical, pretty bool) ([]byte, error) {
+	opts := outputOptions{pretty: pretty, indent: defaultIndent, canonical: canonical}
+
+	return marshalExtJSON(r.withExtJSONMode(opts), opts)
+}
+
+// withExtJSONMode returns a copy of the report whose ExtJSONMode reflects
+// opts.
+func (r Report) withExtJSONMode(opts outputOptions) Report {
+	r.ExtJSONMode = extJSONRelaxed
+	if opts.canonical {
+		r.ExtJSONMode = extJSONCanonical
+	}
+
+	return r
+}
+
+// marshalExtJSON encodes value per opts’ JSON settings.
+func marshalExtJSON(value any, opts outputOptions) ([]byte, error) {
+	json, err := marshalReportExtJSON(value, opts.canonical, opts.escapeHTML)
+	if err != nil || !opts.pretty {
+		return json, err
+	}
+
+	buf := &bytes.Buffer{}
+	err = bson.IndentExtJSON(buf, json, "", strings.Repeat(" ", opts.indent))
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeJSON(out io.Writer, report Report, opts outputOptions) error {
+	var json []byte
+	var err error
+
+	report = report.withExtJSONMode(opts)
+
+	if opts.prettyMetadata && !opts.pretty {
+		json, err = marshalExtJSONWithPrettyMetadata(report, opts)
+	} else {
+		json, err = marshalExtJSON(report, opts)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to encode archive report")
+	}
+
+	_, err = io.Copy(out, bytes.NewBuffer(json))
+	if err != nil {
+		return errors.Wrap(err, "failed to output report")
+	}
+
+	return nil
+}
+
+// marshalExtJSONWithPrettyMetadata is like compact Extended JSON except
+// that each namespace’s parsed metadata is indented.
+func marshalExtJSONWithPrettyMetadata(report Report, opts outputOptions) ([]byte, error) {
+	doc, err := toDocument(report)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+
+	for i, elem := range doc {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		mdDocs, ok := elem.Value.(bson.A)
+		if elem.Key != "collectionMetadata" || !ok {
+			err := writeCompactExtJSONElement(&buf, elem, opts)
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		writeJSONKey(&buf, elem.Key, opts.escapeHTML)
+		buf.WriteByte('[')
+
+		for j, mdDoc := range mdDocs {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+
+			buf.WriteByte('{')
+
+			fields, _ := mdDoc.(bson.D)
+			for k, field := range fields {
+				if k > 0 {
+					buf.WriteByte(',')
+				}
+
+				metadata, isDoc := field.Value.(bson.D)
+				if field.Key != "metadata" || !isDoc {
+					err := writeCompactExtJSONElement(&buf, field, opts)
+					if err != nil {
+						return nil, err
+					}
+
+					continue
+				}
+
+				metadataOpts := opts
+				metadataOpts.pretty = true
+
+				json, err := marshalExtJSON(metadata, metadataOpts)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to encode collection metadata")
+				}
+
+				writeJSONKey(&buf, field.Key, opts.escapeHTML)
+				buf.Write(json)
+			}
+
+			buf.WriteByte('}')
+		}
+
+		buf.WriteByte(']')
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// writeCompactExtJSONElement writes a single `"key":value` pair. Extended
+// JSON marshaling requires a document, so we marshal a one-field document
+// and strip its braces.
+func writeCompactExtJSONElement(buf *bytes.Buffer, elem bson.E, opts outputOptions) error {
+	json, err := marshalReportExtJSON(bson.D{elem}, opts.canonical, opts.escapeHTML)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode %#q", elem.Key)
+	}
+
+	buf.Write(json[1 : len(json)-1])
+
+	return nil
+}
+
+func writeJSONKey(buf *bytes.Buffer, key string, escapeHTML bool) {
+	encoder := encjson.NewEncoder(buf)
+	encoder.SetEscapeHTML(escapeHTML)
+
+	// Encoding a string cannot fail. The encoder ends its output with a
+	// newline, which we replace.
+	_ = encoder.Encode(key)
+
+	buf.Truncate(buf.Len() - 1)
+	buf.WriteByte(':')
+}
+
+// writeHeader writes just the archive header. Only JSON and BSON can
+// represent it.
+func writeHeader(out io.Writer, header bson.D, opts outputOptions) error {
+	var encoded []byte
+	var err error
+
+	switch opts.format {
+	case formatBSON:
+		encoded, err = bson.Marshal(header)
+	case formatJSON, "":
+		encoded, err = marshalExtJSON(header, opts)
+	default:
+		return fmt.Errorf("cannot write archive header as %#q", opts.format)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to encode archive header")
+	}
+
+	_, err = out.Write(encoded)
+	if err != nil {
+		return errors.Wrap(err, "failed to output archive header")
+	}
+
+	return nil
+}
+
+// writeIndexes writes the report’s index definitions as an Extended JSON
+// array with one element per index.
+func writeIndexes(out io.Writer, report Report, opts outputOptions) error {
+	buf := bytes.Buffer{}
+	buf.WriteByte('[')
+
+	for _, definition := range getIndexDefinitions(report.CollectionMetadata) {
+		json, err := marshalExtJSON(definition, opts)
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode index %#q of %s.%s", definition.Name, definition.DB, definition.Collection)
+		}
+
+		writeArrayElement(&buf, json, opts)
+	}
+
+	closeArray(&buf, opts)
+
+	_, err := io.Copy(out, &buf)
+	if err != nil {
+		return errors.Wrap(err, "failed to output index definitions")
+	}
+
+	return nil
+}
+
+// writeArrayElement appends an encoded element to buf, which holds an
+// array that begins with “[”. Pretty output indents each element one
+// level within the array.
+func writeArrayElement(buf *bytes.Buffer, json []byte, opts outputOptions) {
+	if buf.Len() > 1 {
+		buf.WriteByte(',')
+	}
+
+	if !opts.pretty {
+		buf.Write(json)
+		return
+	}
+
+	prefix := "\n" + strings.Repeat(" ", opts.indent)
+	buf.WriteString(prefix)
+	buf.Write(bytes.ReplaceAll(json, []byte("\n"), []byte(prefix)))
+}
+
+// closeArray ends the array that writeArrayElement has been filling.
+func closeArray(buf *bytes.Buffer, opts outputOptions) {
+	if opts.pretty && buf.Len() > 1 {
+		buf.WriteByte('\n')
+	}
+
+	buf.WriteByte(']')
+}
+
+// writeNDJSON writes newline-delimited Extended JSON: the archive header
+// (or an empty document, if the report lacks it) on the first line, then
+// each collection metadata document on its own line. Pretty-printing
+// doesn’t apply, since each document must fit on one line.
+func writeNDJSON(out io.Writer, report Report, opts outputOptions) error {
+	opts.pretty = false
+
+	buf := bytes.Buffer{}
+
+	header := report.Header
+	if header == nil {
+		header = bson.D{}
+	}
+
+	json, err := marshalExtJSON(header, opts)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode archive header")
+	}
+
+	buf.Write(json)
+	buf.WriteByte('\n')
+
+	for _, mdDoc := range report.CollectionMetadata {
+		json, err := marshalExtJSON(mdDoc, opts)
+		if err != nil {
+			db, coll := getNamespace(mdDoc)
+			return errors.Wrapf(err, "failed to encode %s.%s metadata", db, coll)
+		}
+
+		buf.Write(json)
+		buf.WriteByte('\n')
+	}
+
+	_, err = io.Copy(out, &buf)
+	if err != nil {
+		return errors.Wrap(err, "failed to output report")
+	}
+
+	return nil
+}
+
+// writeBSON writes the report as a single raw BSON document.
+func writeBSON(out io.Writer, report Report) error {
+	raw, err := marshalReportBSON(report)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode archive report to BSON")
+	}
+
+	_, err = out.Write(raw)
+	if err != nil {
+		return errors.Wrap(err, "failed to output report")
+	}
+
+	return nil
+}
+
+// toDocument converts the report to a bson.D via its BSON encoding.
+func toDocument(report Report) (bson.D, error) {
+	raw, err := marshalReportBSON(report)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode archive report to BSON")
+	}
+
+	doc := bson.D{}
+	err = bson.Unmarshal(raw, &doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode archive report from BSON")
+	}
+
+	return doc, nil
+}
+
+// checkRoundtrip verifies that the report survives conversion to
+// Extended JSON and back without changing its BSON representation.
+func checkRoundtrip(report Report) error {
+	// Go maps have no stable order, so we compare from a bson.D.
+	doc, err := toDocument(report)
+	if err != nil {
+		return err
+	}
+
+	// Relaxed mode is lossy by design (e.g., it turns small int64s into
+	// int32s), so only canonical mode can round-trip exactly.
+	_, err = bsonutil.MarshalExtJSONWithBSONRoundtripConsistency(doc, true, false)
+	if err != nil {
+		return errors.Wrap(err, "archive report does not survive Extended JSON round trip")
+	}
+
+	return nil
+}
+
+var namespaceColumns = []string{"db", "collection", "type", "indexCount", "capped", "size"}
+
+// documentCountColumn follows namespaceColumns if the report counts
+// documents.
+const documentCountColumn = "documentCount"
+
+// getNamespaceColumns returns the column names for getNamespaceRows.
+func getNamespaceColumns(report Report) []string {
+	if report.DocumentCounts == nil {
+		return namespaceColumns
+	}
+
+	return append(slices.Clone(namespaceColumns), documentCountColumn)
+}
+
+// getNamespaceRows returns one row per namespace in the report, with the
+// columns that getNamespaceColumns names. A namespace with no documents
+// in the archive body, like a view, has an empty document count.
+func getNamespaceRows(report Report) [][]string {
+	rows := make([][]string, 0, len(report.CollectionMetadata))
+
+	for _, mdDoc := range report.CollectionMetadata {
+		db, coll := getNamespace(mdDoc)
+		collType, _ := bsonutil.FindStringValueByKey("type", &mdDoc)
+
+		size := ""
+		if sizeNum, err := bsonutil.FindIntByKey("size", &mdDoc); err == nil {
+			size = strconv.Itoa(sizeNum)
+		}
+
+		row := []string{
+			db,
+			coll,
+			collType,
+			strconv.Itoa(len(getIndexes(mdDoc))),
+			strconv.FormatBool(isCapped(mdDoc)),
+			size,
+		}
+
+		if report.DocumentCounts != nil {
+			count := ""
+			if countNum, ok := report.DocumentCounts[db+"."+coll]; ok {
+				count = strconv.FormatInt(countNum, 10)
+			}
+
+			row = append(row, count)
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// writeCSV writes one row per namespace in the report.
+func writeCSV(out io.Writer, report Report) error {
+	writer := csv.NewWriter(out)
+
+	err := writer.Write(getNamespaceColumns(report))
+	if err != nil {
+		return errors.Wrap(err, "failed to write CSV header")
+	}
+
+	for _, row := range getNamespaceRows(report) {
+		err := writer.Write(row)
+		if err != nil {
+			return errors.Wrapf(err, "failed to write CSV row for %s.%s", row[0], row[1])
+		}
+	}
+
+	writer.Flush()
+
+	return errors.Wrap(writer.Error(), "failed to write CSV")
+}
+
+// writeTable writes a human-readable table with one row per namespace.
+// If color is true, it bolds the column names, dims system collections,
+// and colors views.
+func writeTable(out io.Writer, report Report, color bool) error {
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	if report.ConcurrentCollections > 0 {
+		_, _ = fmt.Fprintf(
+			writer,
+			"Concurrent collections: %d (a namespace’s documents may be split across interleaved blocks)\n\n",
+			report.ConcurrentCollections,
+		)
+	}
+
+	writeRow := func(row []string, style string) {
+		line := strings.Join(row, "\t")
+		if color {
+			// Every row gets a style, even if only ansiReset, so that
+			// all rows’ first cells are equally widened.
+			line = style + line + ansiReset
+		}
+
+		_, _ = fmt.Fprintln(writer, line)
+	}
+
+	writeRow(getNamespaceColumns(report), ansiBold)
+
+	for _, row := range getNamespaceRows(report) {
+		style := ansiReset
+
+		switch {
+		case row[2] == "view":
+			style = ansiCyan
+		case strings.HasPrefix(row[1], "system."):
+			style = ansiDim
+		}
+
+		writeRow(row, style)
+	}
+
+	return errors.Wrap(writer.Flush(), "failed to write table")
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func getTestReport(t *testing.T) Report {
+	file, err := os.Open("test.dump")
+	require.NoError(t, err, "should open dump file")
+	defer func() { _ = file.Close() }()
+
+	report, err := getReport(file, os.Stderr, ParseOptions{})
+	require.NoError(t, err, "should parse dump")
+
+	return report
+}
+
+func TestWriteCSV(t *testing.T) {
+	buf := bytes.Buffer{}
+	require.NoError(t, writeCSV(&buf, getTestReport(t)), "should write CSV")
+
+	assert.Equal(
+		t,
+		"db,collection,type,indexCount,capped,size\n"+
+			"testDB,testColl,collection,1,false,0\n"+
+			"admin,system.users,collection,2,false,0\n"+
+			"admin,system.roles,collection,2,false,0\n"+
+			"admin,system.version,collection,1,false,0\n",
+		buf.String(),
+		"should write one row per namespace",
+	)
+}
+
+func TestWriteCSVDocumentCounts(t *testing.T) {
+	file, err := os.Open("test.dump")
+	require.NoError(t, err, "should open dump file")
+	defer func() { _ = file.Close() }()
+
+	report, err := getReport(file, os.Stderr, ParseOptions{CountDocuments: true})
+	require.NoError(t, err, "should parse dump")
+
+	report.CollectionMetadata = append(report.CollectionMetadata, makeMetadataDoc("testDB", "view"))
+
+	buf := bytes.Buffer{}
+	require.NoError(t, writeCSV(&buf, report), "should write CSV")
+
+	assert.Equal(
+		t,
+		"db,collection,type,indexCount,capped,size,documentCount\n"+
+			"testDB,testColl,collection,1,false,0,1500\n"+
+			"admin,system.users,collection,2,false,0,4\n"+
+			"admin,system.roles,collection,2,false,0,4\n"+
+			"admin,system.version,collection,1,false,0,2\n"+
+			"testDB,view,,0,false,,\n",
+		buf.String(),
+		"should add document counts when available",
+	)
+}
+
+func TestWriteTable(t *testing.T) {
+	buf := bytes.Buffer{}
+	require.NoError(t, writeTable(&buf, getTestReport(t), false), "should write table")
+
+	assert.Equal(
+		t,
+		"Concurrent collections: 4 (a namespace’s documents may be split across interleaved blocks)\n"+
+			"\n"+
+			"db      collection      type        indexCount  capped  size\n"+
+			"testDB  testColl        collection  1           false   0\n"+
+			"admin   system.users    collection  2           false   0\n"+
+			"admin   system.roles    collection  2           false   0\n"+
+			"admin   system.version  collection  1           false   0\n",
+		buf.String(),
+		"should write aligned rows with a concurrency note",
+	)
+}
+
+func TestWriteTableColor(t *testing.T) {
+	report := Report{
+		CollectionMetadata: []bson.D{
+			append(makeMetadataDoc("db", "coll"), bson.E{Key: "type", Value: "collection"}),
+			append(makeMetadataDoc("db", "v"), bson.E{Key: "type", Value: "view"}),
+			append(makeMetadataDoc("db", "system.js"), bson.E{Key: "type", Value: "collection"}),
+		},
+	}
+
+	buf := bytes.Buffer{}
+	require.NoError(t, writeTable(&buf, report, true), "should write table")
+
+	assert.Equal(
+		t,
+		ansiBold+"db  collection  type        indexCount  capped  size"+ansiReset+"\n"+
+			ansiReset+"db  coll        collection  0           false   "+ansiReset+"\n"+
+			ansiCyan+"db  v           view        0           false   "+ansiReset+"\n"+
+			ansiDim+"db  system.js   collection  0           false   "+ansiReset+"\n",
+		buf.String(),
+		"should color rows without misaligning them",
+	)
+}
+
+func TestUseColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	assert.False(t, useColor(colorAuto, os.Stdout), "$NO_COLOR should disable auto color")
+	assert.True(t, useColor(colorAlways, os.Stdout), "--color=always should override $NO_COLOR")
+
+	t.Setenv("NO_COLOR", "")
+	assert.False(t, useColor(colorNever, os.Stdout), "--color=never should disable color")
+}
+
+func TestMarshalExtJSON(t *testing.T) {
+	report := getTestReport(t)
+
+	buf := bytes.Buffer{}
+	require.NoError(t, writeJSON(&buf, report, outputOptions{}), "should write JSON")
+
+	json, err := report.MarshalExtJSON(false, false)
+	require.NoError(t, err, "should marshal report")
+	assert.Equal(t, buf.String(), string(json), "should match CLI output")
+
+	for _, canonical := range []bool{false, true} {
+		for _, pretty := range []bool{false, true} {
+			json, err := report.MarshalExtJSON(canonical, pretty)
+			require.NoError(t, err, "should marshal report (canonical=%t, pretty=%t)", canonical, pretty)
+
+			roundtripped := Report{}
+			err = bson.UnmarshalExtJSON(json, canonical, &roundtripped)
+			require.NoError(t, err, "should unmarshal report (canonical=%t, pretty=%t)", canonical, pretty)
+
+			assert.Equal(
+				t,
+				report.withExtJSONMode(outputOptions{canonical: canonical}),
+				roundtripped,
+				"should round-trip (canonical=%t, pretty=%t)", canonical, pretty,
+			)
+		}
+	}
+}
+
+func TestExtJSONMode(t *testing.T) {
+	report := Report{Header: bson.D{{Key: "n", Value: int64(1)}}}
+
+	buf := bytes.Buffer{}
+	require.NoError(t, writeJSON(&buf, report, outputOptions{}), "should write JSON")
+	assert.True(
+		t,
+		strings.HasPrefix(buf.String(), `{"extJsonMode":"relaxed","header":{"n":1},`),
+		"should note relaxed mode first: %s", buf.String(),
+	)
+
+	buf.Reset()
+	require.NoError(t, writeJSON(&buf, report, outputOptions{canonical: true}), "should write JSON")
+	assert.Contains(
+		t,
+		buf.String(),
+		`{"extJsonMode":"canonical","header":{"n":{"$numberLong":"1"}}`,
+		"should note & use canonical mode",
+	)
+
+	assert.Empty(t, report.ExtJSONMode, "should not modify the report")
+}
+
+func TestWritePrettyMetadata(t *testing.T) {
+	report := getTestReport(t)
+
+	buf := bytes.Buffer{}
+	require.NoError(
+		t,
+		writeJSON(&buf, report, outputOptions{prettyMetadata: true, indent: 2}),
+		"should write JSON",
+	)
+
+	assert.Contains(t, buf.String(), `"collectionMetadata":[{"db":"testDB","collection":"testColl","metadata":{`+"\n"+`  "indexes": [`, "should indent metadata")
+	assert.Contains(t, buf.String(), `"oplog":{"present":false},"bytesRead":1448`, "should keep other fields compact")
+
+	roundtripped := Report{}
+	require.NoError(t, bson.UnmarshalExtJSON(buf.Bytes(), false, &roundtripped), "should be valid Extended JSON")
+	assert.Equal(t, report.withExtJSONMode(outputOptions{}), roundtripped, "should encode the same report")
+}
+
+func TestWriteIndent(t *testing.T) {
+	report := Report{Header: bson.D{{Key: "n", Value: int32(1)}, {Key: "sub", Value: bson.D{{Key: "a", Value: true}}}}}
+
+	for indent, expected := range map[int]string{
+		0: "{\n\"extJsonMode\": \"relaxed\",\n\"header\": {\n\"n\": 1,\n\"sub\": {\n\"a\": true\n}\n},",
+		4: "{\n    \"extJsonMode\": \"relaxed\",\n    \"header\": {\n        \"n\": 1,\n        \"sub\": {\n            \"a\": true\n        }\n    },",
+	} {
+		buf := bytes.Buffer{}
+		require.NoError(t, writeJSON(&buf, report, outputOptions{pretty: true, indent: indent}), "should write JSON (indent %d)", indent)
+		assert.True(t, strings.HasPrefix(buf.String(), expected), "should indent by %d spaces: %s", indent, buf.String())
+
+		roundtripped := Report{}
+		require.NoError(t, bson.UnmarshalExtJSON(buf.Bytes(), false, &roundtripped), "should be valid Extended JSON (indent %d)", indent)
+		assert.Equal(t, report.Header, roundtripped.Header, "indenting should not change content (indent %d)", indent)
+	}
+
+	metadataReport := getTestReport(t)
+
+	buf := bytes.Buffer{}
+	require.NoError(
+		t,
+		writeJSON(&buf, metadataReport, outputOptions{prettyMetadata: true, indent: 4}),
+		"should write JSON",
+	)
+	assert.Contains(
+		t,
+		buf.String(),
+		`"metadata":{`+"\n"+`    "indexes": [`+"\n"+`        {`,
+		"should indent metadata by 4 spaces",
+	)
+}
+
+func TestWriteEscapeHTML(t *testing.T) {
+	report := Report{
+		Header: bson.D{{Key: "<key>", Value: "a < b && b > c"}},
+		CollectionMetadata: []bson.D{
+			append(makeMetadataDoc("db", "coll"), bson.E{Key: "metadata", Value: bson.D{
+				{Key: "options", Value: bson.D{{Key: "validator", Value: bson.D{{Key: "$expr", Value: "<&>"}}}}},
+			}}),
+		},
+	}
+
+	for _, opts := range []outputOptions{{}, {pretty: true, indent: 2}, {prettyMetadata: true, indent: 2}} {
+		buf := bytes.Buffer{}
+		require.NoError(t, writeJSON(&buf, report, opts), "should write JSON (%+v)", opts)
+		assert.Contains(t, buf.String(), "a < b && b > c", "should not escape by default (%+v)", opts)
+
+		opts.escapeHTML = true
+
+		buf.Reset()
+		require.NoError(t, writeJSON(&buf, report, opts), "should write JSON (%+v)", opts)
+		assert.NotContains(t, buf.String(), "<", "should escape (%+v)", opts)
+		assert.NotContains(t, buf.String(), "&", "should escape (%+v)", opts)
+		assert.Contains(t, buf.String(), `a \u003c b \u0026\u0026 b \u003e c`, "should escape (%+v)", opts)
+
+		roundtripped := Report{}
+		require.NoError(t, bson.UnmarshalExtJSON(buf.Bytes(), false, &roundtripped), "should be valid Extended JSON (%+v)", opts)
+		assert.Equal(t, report.Header, roundtripped.Header, "escaping should not change content (%+v)", opts)
+	}
+}
+
+func TestWriteHeader(t *testing.T) {
+	header := getTestReport(t).Header
+
+	buf := bytes.Buffer{}
+	require.NoError(t, writeHeader(&buf, header, outputOptions{format: formatJSON}), "should write JSON")
+	assert.Equal(
+		t,
+		`{"concurrent_collections":4,"version":"0.1","server_version":"8.0.3-120-gbc35ab4","tool_version":"100.7.1"}`,
+		buf.String(),
+		"should write header as JSON",
+	)
+
+	buf.Reset()
+	require.NoError(t, writeHeader(&buf, header, outputOptions{format: formatBSON}), "should write BSON")
+	decoded := bson.D{}
+	require.NoError(t, bson.Unmarshal(buf.Bytes(), &decoded), "should decode BSON")
+	assert.Equal(t, header, decoded, "should write header as BSON")
+
+	assert.Error(t, writeHeader(&buf, header, outputOptions{format: formatCSV}), "should reject CSV")
+}
+
+func TestWriteBSON(t *testing.T) {
+	report := getTestReport(t)
+
+	buf := bytes.Buffer{}
+	require.NoError(t, writeBSON(&buf, report), "should write BSON")
+
+	raw, err := bson.ReadDocument(&buf)
+	require.NoError(t, err, "output should be a BSON document")
+	assert.Zero(t, buf.Len(), "output should be nothing but the document")
+
+	decoded := Report{}
+	require.NoError(t, bson.Unmarshal(raw, &decoded), "should decode output")
+	assert.Equal(t, report, decoded, "should round-trip")
+}
+
+func TestCheckRoundtrip(t *testing.T) {
+	report := getTestReport(t)
+	assert.NoError(t, checkRoundtrip(report), "test dump’s report should round-trip")
+
+	report.Header = append(report.Header, bson.E{Key: "nan", Value: math.NaN()})
+	assert.NoError(t, checkRoundtrip(report), "NaN should round-trip in canonical mode")
+
+	report.Header = append(report.Header, bson.E{Key: "ambiguous", Value: bson.D{
+		{Key: "$numberLong", Value: "123"},
+	}})
+	assert.Error(t, checkRoundtrip(report), "ext JSON lookalike should fail round trip")
+}
+
+func TestWriteIndexes(t *testing.T) {
+	report := Report{
+		CollectionMetadata: []bson.D{
+			makeMetadataDocWithIndexes("db", "a", bson.D{}, bson.A{
+				bson.D{
+					{Key: "v", Value: int32(2)},
+					{Key: "key", Value: bson.D{{Key: "x", Value: int32(1)}}},
+					{Key: "name", Value: "x_1"},
+					{Key: "ns", Value: "db.a"},
+					{Key: "unique", Value: true},
+				},
+			}),
+			makeMetadataDoc("db", "noIndexes"),
+			makeMetadataDocWithIndexes("db", "b", bson.D{}, bson.A{
+				bson.D{{Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}}, {Key: "name", Value: "_id_"}},
+			}),
+		},
+	}
+
+	buf := bytes.Buffer{}
+	require.NoError(t, writeReport(&buf, report, outputOptions{format: formatIndexes}), "should write indexes")
+	assert.Equal(
+		t,
+		`[{"db":"db","collection":"a","name":"x_1","key":{"x":1},"options":{"v":2,"unique":true}},`+
+			`{"db":"db","collection":"b","name":"_id_","key":{"_id":1},"options":{}}]`,
+		buf.String(),
+		"should write one element per index",
+	)
+
+	buf.Reset()
+	require.NoError(t, writeReport(&buf, report, outputOptions{format: formatIndexes, pretty: true, indent: 2}), "should write indexes")
+
+	var decoded []map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded), "pretty output should be valid JSON")
+	assert.Len(t, decoded, 2, "pretty output should have one element per index")
+	assert.Contains(t, buf.String(), "[\n  {\n    \"db\": \"db\",", "should indent elements within the array")
+
+	buf.Reset()
+	require.NoError(t, writeReport(&buf, Report{}, outputOptions{format: formatIndexes, pretty: true}), "should write no indexes")
+	assert.Equal(t, "[]", buf.String(), "should write empty array")
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	report := getTestReport(t)
+
+	buf := bytes.Buffer{}
+	require.NoError(t, writeReport(&buf, report, outputOptions{format: formatNDJSON, pretty: true, indent: 2}), "should write NDJSON")
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 1+len(report.CollectionMetadata), "should write header & each namespace on its own line")
+
+	header := bson.D{}
+	require.NoError(t, bson.UnmarshalExtJSON([]byte(lines[0]), false, &header), "header line should be valid ext JSON")
+	assert.Equal(t, report.Header, header, "should write header first")
+
+	for i, line := range lines[1:] {
+		mdDoc := bson.D{}
+		require.NoError(t, bson.UnmarshalExtJSON([]byte(line), false, &mdDoc), "line %d should be valid ext JSON", i+2)
+		assert.Equal(t, report.CollectionMetadata[i], mdDoc, "should write metadata in order")
+	}
+
+	buf.Reset()
+	require.NoError(t, writeReport(&buf, Report{}, outputOptions{format: formatNDJSON}), "should write NDJSON")
+	assert.Equal(t, "{}\n", buf.String(), "should write empty header without one")
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// GridFSBucket describes a GridFS bucket, i.e., a pair of collections
+// named “<bucket>.files” & “<bucket>.chunks”.
+type GridFSBucket struct {
+	DB     string `bson:"db"`
+	Bucket string `bson:"bucket"`
+
+	// Files & Chunks are how many documents the bucket’s collections
+	// contain. They are present only if documents were counted.
+	Files  *int64 `bson:"files,omitempty"`
+	Chunks *int64 `bson:"chunks,omitempty"`
+
+	// Bytes is the size of both collections’ documents. It is present
+	// only if the archive body was read to count documents or estimate
+	// sizes.
+	Bytes *int64 `bson:"bytes,omitempty"`
+
+	// Missing names the collection (“files” or “chunks”) that an
+	// orphaned bucket lacks.
+	Missing string `bson:"missing,omitempty"`
+}
+
+// gridFSIndexes maps each GridFS bucket collection’s suffix to the name
+// of the index that GridFS drivers create on it.
+var gridFSIndexes = map[string]string{
+	".files":  "filename_1_uploadDate_1",
+	".chunks": "files_id_1_n_1",
+}
+
+// getGridFSBuckets finds the GridFS buckets among the given collections.
+// A collection with one of the GridFS suffixes whose counterpart is
+// absent yields an orphaned bucket, but only if it has its GridFS index;
+// otherwise it is likely an ordinary collection, like “project.files”.
+// Views are ignored. If docCounts or docBytes is non-nil, the buckets
+// include those totals.
+func getGridFSBuckets(mdDocs []bson.D, docCounts, docBytes map[string]int64) []GridFSBucket {
+	type bucketKey struct{ db, bucket string }
+
+	// halves maps each bucket’s collection suffixes to whether those
+	// collections have their GridFS indexes.
+	halves := map[bucketKey]map[string]bool{}
+
+	for _, mdDoc := range mdDocs {
+		if collType, _ := bsonutil.FindStringValueByKey("type", &mdDoc); collType == "view" {
+			continue
+		}
+
+		db, coll := getNamespace(mdDoc)
+
+		for suffix, indexName := range gridFSIndexes {
+			if bucket, ok := strings.CutSuffix(coll, suffix); ok && bucket != "" {
+				key := bucketKey{db, bucket}
+				if halves[key] == nil {
+					halves[key] = map[string]bool{}
+				}
+				halves[key][suffix] = hasIndexNamed(mdDoc, indexName)
+			}
+		}
+	}
+
+	var buckets []GridFSBucket
+
+	for key, found := range halves {
+		bucket := GridFSBucket{DB: key.db, Bucket: key.bucket}
+		filesNS := key.db + "." + key.bucket + ".files"
+		chunksNS := key.db + "." + key.bucket + ".chunks"
+
+		if len(found) == 1 {
+			switch {
+			case found[".chunks"]:
+				bucket.Missing = "files"
+			case found[".files"]:
+				bucket.Missing = "chunks"
+			default:
+				continue
+			}
+		}
+
+		if docCounts != nil {
+			files, chunks := docCounts[filesNS], docCounts[chunksNS]
+			bucket.Files, bucket.Chunks = &files, &chunks
+		}
+
+		if docBytes != nil {
+			bytes := docBytes[filesNS] + docBytes[chunksNS]
+			bucket.Bytes = &bytes
+		}
+
+		buckets = append(buckets, bucket)
+	}
+
+	slices.SortFunc(buckets, func(a, b GridFSBucket) int {
+		return cmp.Or(cmp.Compare(a.DB, b.DB), cmp.Compare(a.Bucket, b.Bucket))
+	})
+
+	return buckets
+}
+
+// hasIndexNamed indicates whether a collection’s metadata includes an
+// index with the given name.
+func hasIndexNamed(mdDoc bson.D, name string) bool {
+	return slices.ContainsFunc(getIndexes(mdDoc), func(index bson.D) bool {
+		indexName, _ := bsonutil.FindStringValueByKey("name", &index)
+		return indexName == name
+	})
+}
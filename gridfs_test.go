@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGridFSBuckets(t *testing.T) {
+	chunksIndex := bson.D{
+		{Key: "key", Value: bson.D{{Key: "files_id", Value: 1}, {Key: "n", Value: 1}}},
+		{Key: "name", Value: "files_id_1_n_1"},
+	}
+
+	mdDocs := []bson.D{
+		makeMetadataDoc("db", "fs.files"),
+		makeMetadataDoc("db", "fs.chunks"),
+		makeMetadataDocWithIndexes("db", "photos.chunks", bson.D{}, bson.A{chunksIndex}),
+		makeMetadataDoc("db", "users"),
+		makeMetadataDoc("other", ".files"),
+		append(makeMetadataDoc("other", "docs.files"), bson.E{Key: "type", Value: "view"}),
+	}
+
+	assert.Equal(
+		t,
+		[]GridFSBucket{
+			{DB: "db", Bucket: "fs"},
+			{DB: "db", Bucket: "photos", Missing: "files"},
+		},
+		getGridFSBuckets(mdDocs, nil, nil),
+		"should pair bucket collections & flag orphans",
+	)
+
+	files, chunks, bytes := int64(2), int64(5), int64(300)
+	noFiles, noChunks, photoBytes := int64(0), int64(1), int64(100)
+
+	assert.Equal(
+		t,
+		[]GridFSBucket{
+			{DB: "db", Bucket: "fs", Files: &files, Chunks: &chunks, Bytes: &bytes},
+			{DB: "db", Bucket: "photos", Files: &noFiles, Chunks: &noChunks, Bytes: &photoBytes, Missing: "files"},
+		},
+		getGridFSBuckets(
+			mdDocs,
+			map[string]int64{"db.fs.files": 2, "db.fs.chunks": 5, "db.photos.chunks": 1},
+			map[string]int64{"db.fs.files": 100, "db.fs.chunks": 200, "db.photos.chunks": 100},
+		),
+		"should total counts & sizes",
+	)
+
+	assert.Nil(t, getGridFSBuckets([]bson.D{makeMetadataDoc("db", "users")}, nil, nil), "should find no buckets")
+
+	assert.Nil(
+		t,
+		getGridFSBuckets([]bson.D{
+			makeMetadataDoc("db", "project.files"),
+			makeMetadataDocWithIndexes("db", "audit.chunks", bson.D{}, bson.A{
+				bson.D{{Key: "key", Value: bson.D{{Key: "ts", Value: 1}}}, {Key: "name", Value: "ts_1"}},
+			}),
+		}, nil, nil),
+		"should not call a lone collection without its GridFS index an orphaned bucket",
+	)
+}
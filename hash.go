@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/pkg/errors"
+)
+
+// InputHash is a digest of the entire raw input, e.g., to tell whether two
+// backups are byte-identical.
+type InputHash struct {
+	Algorithm string `bson:"algorithm"`
+	Digest    string `bson:"digest"`
+}
+
+var hashAlgorithms = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+func hashAlgorithmNames() []string {
+	return slices.Sorted(maps.Keys(hashAlgorithms))
+}
+
+func validateHashAlgorithm(algorithm string) error {
+	if _, ok := hashAlgorithms[algorithm]; !ok {
+		return fmt.Errorf("hash algorithm must be one of %v, not %#q", hashAlgorithmNames(), algorithm)
+	}
+
+	return nil
+}
+
+// getHashedReport is getReport plus a digest of the raw input. Because
+// the digest covers all of the input, this reads whatever the report
+// itself doesn’t need.
+func getHashedReport(input io.Reader, errOut io.Writer, opts ParseOptions) (Report, error) {
+	err := validateHashAlgorithm(opts.HashAlgorithm)
+	if err != nil {
+		return Report{}, err
+	}
+
+	algorithm := opts.HashAlgorithm
+	hasher := hashAlgorithms[algorithm]()
+	input = io.TeeReader(input, hasher)
+
+	opts.HashAlgorithm = ""
+	report, err := getReport(input, errOut, opts)
+	if err != nil {
+		return Report{}, err
+	}
+
+	// A followed input would wait out its idle timeout here, so we hash
+	// only the input read so far.
+	if !opts.Follow {
+		_, err = io.Copy(io.Discard, input)
+		if err != nil {
+			return Report{}, errors.Wrap(err, "failed to read remaining input to hash")
+		}
+	}
+
+	report.InputHash = &InputHash{
+		Algorithm: algorithm,
+		Digest:    hex.EncodeToString(hasher.Sum(nil)),
+	}
+
+	return report, nil
+}
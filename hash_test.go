@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInputHash(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	digest := sha256.Sum256(dump)
+
+	for _, opts := range []ParseOptions{
+		{HashAlgorithm: "sha256"},
+		{HashAlgorithm: "sha256", HeaderOnly: true},
+		{HashAlgorithm: "sha256", CountDocuments: true},
+	} {
+		report, err := getReport(bytes.NewReader(dump), io.Discard, opts)
+		require.NoError(t, err, "should parse dump (%+v)", opts)
+
+		assert.Equal(
+			t,
+			&InputHash{Algorithm: "sha256", Digest: hex.EncodeToString(digest[:])},
+			report.InputHash,
+			"should hash entire input (%+v)",
+			opts,
+		)
+	}
+
+	compressed := bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(&compressed)
+	_, err = gzipWriter.Write(dump)
+	require.NoError(t, err, "should compress dump")
+	require.NoError(t, gzipWriter.Close(), "should compress dump")
+
+	compressedDigest := sha256.Sum256(compressed.Bytes())
+
+	report, err := getReport(&compressed, io.Discard, ParseOptions{HashAlgorithm: "sha256"})
+	require.NoError(t, err, "should parse compressed dump")
+	assert.Equal(t, hex.EncodeToString(compressedDigest[:]), report.InputHash.Digest, "should hash raw input")
+
+	_, err = getReport(bytes.NewReader(dump), io.Discard, ParseOptions{HashAlgorithm: "crc32"})
+	assert.Error(t, err, "should reject unknown algorithm")
+}
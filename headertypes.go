@@ -0,0 +1,28 @@
+package main
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// HeaderFieldType is an archive header field’s BSON type, which Extended
+// JSON output may not convey (e.g., relaxed mode writes int32 & int64
+// alike).
+type HeaderFieldType struct {
+	Field string `bson:"field"`
+	Type  string `bson:"type"`
+	Value any    `bson:"value"`
+}
+
+// getHeaderFieldTypes returns the type of each of the header’s fields,
+// in header order.
+func getHeaderFieldTypes(header bson.D) []HeaderFieldType {
+	types := make([]HeaderFieldType, 0, len(header))
+
+	for _, elem := range header {
+		types = append(types, HeaderFieldType{
+			Field: elem.Key,
+			Type:  bsonTypeName(elem.Value),
+			Value: elem.Value,
+		})
+	}
+
+	return types
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"math"
+
+	"github.com/mongodb/mongo-tools/common/archive"
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// idTypeCounter tallies the BSON types of each namespace’s _id values.
+// Unlike other analyzers, it must see every document.
+type idTypeCounter struct {
+	enabled bool
+	types   map[string][]string
+	counts  map[string]map[string]int
+}
+
+func newIDTypeCounter(enabled bool) *idTypeCounter {
+	return &idTypeCounter{
+		enabled: enabled,
+		types:   map[string][]string{},
+		counts:  map[string]map[string]int{},
+	}
+}
+
+func (itc *idTypeCounter) docLimit(archive.NamespaceHeader) int {
+	if itc.enabled {
+		return math.MaxInt
+	}
+
+	return 0
+}
+
+func (itc *idTypeCounter) analyze(header archive.NamespaceHeader, doc bson.D) {
+	ns := header.Database + "." + header.Collection
+
+	typeName := "missing"
+	if id, err := bsonutil.FindValueByKey("_id", &doc); err == nil {
+		typeName = bsonTypeName(id)
+	}
+
+	if itc.counts[ns] == nil {
+		itc.counts[ns] = map[string]int{}
+	}
+
+	if itc.counts[ns][typeName] == 0 {
+		itc.types[ns] = append(itc.types[ns], typeName)
+	}
+	itc.counts[ns][typeName]++
+}
+
+// results returns the count of each _id type, keyed by namespace. Types
+// are in order of appearance.
+func (itc *idTypeCounter) results() map[string]bson.D {
+	results := map[string]bson.D{}
+
+	for ns, types := range itc.types {
+		counts := bson.D{}
+		for _, typeName := range types {
+			counts = append(counts, bson.E{Key: typeName, Value: itc.counts[ns][typeName]})
+		}
+
+		results[ns] = counts
+	}
+
+	return results
+}
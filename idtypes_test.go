@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mongodb/mongo-tools/common/archive"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestIDTypeCounter(t *testing.T) {
+	mixed := archive.NamespaceHeader{Database: "testDB", Collection: "mixed"}
+	uniform := archive.NamespaceHeader{Database: "testDB", Collection: "uniform"}
+
+	counter := newIDTypeCounter(true)
+	counter.analyze(mixed, bson.D{{Key: "_id", Value: primitive.NewObjectID()}})
+	counter.analyze(mixed, bson.D{{Key: "_id", Value: "abc"}})
+	counter.analyze(mixed, bson.D{{Key: "_id", Value: primitive.NewObjectID()}})
+	counter.analyze(mixed, bson.D{{Key: "x", Value: int32(1)}})
+	counter.analyze(uniform, bson.D{{Key: "_id", Value: int64(1)}})
+
+	assert.Equal(
+		t,
+		map[string]bson.D{
+			"testDB.mixed": {
+				{Key: "objectId", Value: 2},
+				{Key: "string", Value: 1},
+				{Key: "missing", Value: 1},
+			},
+			"testDB.uniform": {
+				{Key: "long", Value: 1},
+			},
+		},
+		counter.results(),
+		"should tally _id types per namespace",
+	)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// NamespaceIndexes lists a namespace’s index specifications in normalized
+// form, alongside the specifications as the archive records them.
+type NamespaceIndexes struct {
+	DB         string `bson:"db"`
+	Collection string `bson:"collection"`
+
+	// Indexes are the normalized specifications, ordered by name.
+	Indexes []NormalizedIndex `bson:"indexes"`
+
+	// Original are the specifications exactly as archived.
+	Original []bson.D `bson:"original"`
+}
+
+// NormalizedIndex is an index specification with the variations that
+// different server versions introduce smoothed out, so that equivalent
+// indexes compare equal.
+type NormalizedIndex struct {
+	Name string `bson:"name"`
+
+	// Key is the index key pattern. Its field order is significant, so
+	// it stays as archived, but numeric directions become int32.
+	Key bson.D `bson:"key"`
+
+	// Options are the rest of the specification, ordered by field name.
+	// This omits the index version (“v”) and the legacy namespace
+	// (“ns”), and it represents boolean options as booleans.
+	Options bson.D `bson:"options,omitempty"`
+}
+
+// ignoredIndexFields are index specification fields that vary by server
+// version but don’t describe the index.
+var ignoredIndexFields = []string{"name", "key", "v", "ns"}
+
+// boolIndexOptions are index options that older servers may record as
+// numbers.
+var boolIndexOptions = []string{"background", "dropDups", "hidden", "sparse", "unique"}
+
+// getNamespaceIndexes returns the indexes of each collection metadata
+// document that has any.
+func getNamespaceIndexes(mdDocs []bson.D) []NamespaceIndexes {
+	var allIndexes []NamespaceIndexes
+
+	for _, mdDoc := range mdDocs {
+		indexes := getIndexes(mdDoc)
+		if len(indexes) == 0 {
+			continue
+		}
+
+		nsIndexes := NamespaceIndexes{
+			Indexes:  make([]NormalizedIndex, 0, len(indexes)),
+			Original: indexes,
+		}
+		nsIndexes.DB, nsIndexes.Collection = getNamespace(mdDoc)
+
+		for _, index := range indexes {
+			nsIndexes.Indexes = append(nsIndexes.Indexes, normalizeIndex(index))
+		}
+
+		slices.SortStableFunc(nsIndexes.Indexes, func(a, b NormalizedIndex) int {
+			return cmp.Compare(a.Name, b.Name)
+		})
+
+		allIndexes = append(allIndexes, nsIndexes)
+	}
+
+	return allIndexes
+}
+
+// normalizeIndex returns an index specification’s normalized form.
+func normalizeIndex(index bson.D) NormalizedIndex {
+	normalized := NormalizedIndex{}
+	normalized.Name, _ = bsonutil.FindStringValueByKey("name", &index)
+
+	for _, elem := range getSubdocument(index, "key") {
+		normalized.Key = append(normalized.Key, bson.E{Key: elem.Key, Value: normalizeIndexDirection(elem.Value)})
+	}
+
+	for _, elem := range index {
+		if slices.Contains(ignoredIndexFields, elem.Key) {
+			continue
+		}
+
+		if slices.Contains(boolIndexOptions, elem.Key) {
+			elem.Value = normalizeIndexBool(elem.Value)
+		}
+
+		normalized.Options = append(normalized.Options, elem)
+	}
+
+	slices.SortStableFunc(normalized.Options, func(a, b bson.E) int {
+		return cmp.Compare(a.Key, b.Key)
+	})
+
+	return normalized
+}
+
+// normalizeIndexDirection converts an integral numeric key direction
+// (e.g., 1.0 or NumberLong(-1)) to an int32. Other values, like “text” or
+// “2dsphere”, are unchanged.
+func normalizeIndexDirection(value any) any {
+	var num float64
+
+	switch v := value.(type) {
+	case int32:
+		return v
+	case int64:
+		num = float64(v)
+	case float64:
+		num = v
+	default:
+		return value
+	}
+
+	if num != math.Trunc(num) || num < math.MinInt32 || num > math.MaxInt32 {
+		return value
+	}
+
+	return int32(num)
+}
+
+// normalizeIndexBool converts a numeric boolean option to a boolean.
+func normalizeIndexBool(value any) any {
+	switch v := value.(type) {
+	case int32:
+		return v != 0
+	case int64:
+		return v != 0
+	case float64:
+		return v != 0
+	default:
+		return value
+	}
+}
+
+// IndexDefinition is one index specification along with its namespace.
+// This is what --format=indexes outputs.
+type IndexDefinition struct {
+	DB         string `bson:"db"`
+	Collection string `bson:"collection"`
+	Name       string `bson:"name"`
+	Key        bson.D `bson:"key"`
+
+	// Options are the specification’s other fields, as archived, minus
+	// the legacy namespace (“ns”), which newer servers reject.
+	Options bson.D `bson:"options"`
+}
+
+// getIndexDefinitions flattens the indexes of all collection metadata
+// documents into a single list.
+func getIndexDefinitions(mdDocs []bson.D) []IndexDefinition {
+	definitions := []IndexDefinition{}
+
+	for _, mdDoc := range mdDocs {
+		db, coll := getNamespace(mdDoc)
+
+		for _, index := range getIndexes(mdDoc) {
+			definition := IndexDefinition{
+				DB:         db,
+				Collection: coll,
+				Key:        getSubdocument(index, "key"),
+				Options:    bson.D{},
+			}
+			definition.Name, _ = bsonutil.FindStringValueByKey("name", &index)
+
+			for _, elem := range index {
+				if !slices.Contains([]string{"name", "key", "ns"}, elem.Key) {
+					definition.Options = append(definition.Options, elem)
+				}
+			}
+
+			definitions = append(definitions, definition)
+		}
+	}
+
+	return definitions
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestNamespaceIndexes(t *testing.T) {
+	// The same indexes as archived by an old server and a new one.
+	oldIndexes := bson.A{
+		bson.D{
+			{Key: "v", Value: int32(1)},
+			{Key: "key", Value: bson.D{{Key: "b", Value: float64(-1)}, {Key: "a", Value: int64(1)}}},
+			{Key: "name", Value: "b_-1_a_1"},
+			{Key: "ns", Value: "db.old"},
+			{Key: "unique", Value: float64(1)},
+			{Key: "sparse", Value: int32(1)},
+		},
+		bson.D{
+			{Key: "v", Value: int32(1)},
+			{Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}},
+			{Key: "name", Value: "_id_"},
+			{Key: "ns", Value: "db.old"},
+		},
+	}
+	newIndexes := bson.A{
+		bson.D{
+			{Key: "v", Value: int32(2)},
+			{Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}},
+			{Key: "name", Value: "_id_"},
+		},
+		bson.D{
+			{Key: "v", Value: int32(2)},
+			{Key: "sparse", Value: true},
+			{Key: "unique", Value: true},
+			{Key: "key", Value: bson.D{{Key: "b", Value: int32(-1)}, {Key: "a", Value: int32(1)}}},
+			{Key: "name", Value: "b_-1_a_1"},
+		},
+	}
+
+	allIndexes := getNamespaceIndexes([]bson.D{
+		makeMetadataDocWithIndexes("db", "old", bson.D{}, oldIndexes),
+		makeMetadataDocWithIndexes("db", "new", bson.D{}, newIndexes),
+		makeMetadataDoc("db", "none"),
+	})
+	require.Len(t, allIndexes, 2, "should omit namespaces without indexes")
+
+	assert.Equal(
+		t,
+		[]NormalizedIndex{
+			{Name: "_id_", Key: bson.D{{Key: "_id", Value: int32(1)}}},
+			{
+				Name: "b_-1_a_1",
+				Key:  bson.D{{Key: "b", Value: int32(-1)}, {Key: "a", Value: int32(1)}},
+				Options: bson.D{
+					{Key: "sparse", Value: true},
+					{Key: "unique", Value: true},
+				},
+			},
+		},
+		allIndexes[0].Indexes,
+		"should normalize indexes",
+	)
+	assert.Equal(t, allIndexes[0].Indexes, allIndexes[1].Indexes, "equivalent indexes should match")
+
+	assert.Equal(t, "old", allIndexes[0].Collection, "should preserve namespace")
+	assert.Equal(t, oldIndexes[0], allIndexes[0].Original[0], "should preserve original specifications")
+}
+
+func TestNormalizeIndexDirection(t *testing.T) {
+	assert.Equal(t, int32(1), normalizeIndexDirection(float64(1)), "should convert integral double")
+	assert.Equal(t, int32(-1), normalizeIndexDirection(int64(-1)), "should convert long")
+	assert.Equal(t, 0.5, normalizeIndexDirection(0.5), "should leave fractional double")
+	assert.Equal(t, "2dsphere", normalizeIndexDirection("2dsphere"), "should leave string")
+}
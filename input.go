@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// openInput returns the archive stream that the command should parse.
+func openInput(ctx context.Context, cmd *cli.Command) (io.ReadCloser, error) {
+	if cmd.Bool("parts") {
+		if cmd.String("url") != "" {
+			return nil, fmt.Errorf("--parts cannot be combined with --url")
+		}
+
+		return openParts(cmd.Args().Slice())
+	}
+
+	if cmd.Args().Len() > 1 {
+		return nil, fmt.Errorf("expected at most one archive file, not %d", cmd.Args().Len())
+	}
+
+	path := cmd.Args().First()
+
+	if archiveURL := cmd.String("url"); archiveURL != "" {
+		if path != "" {
+			return nil, fmt.Errorf("cannot read both %#q and a URL", path)
+		}
+
+		return openURL(ctx, archiveURL)
+	}
+
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open %#q", path)
+		}
+
+		return file, nil
+	}
+
+	return io.NopCloser(os.Stdin), nil
+}
+
+// partsReader reads a split archive’s parts as one stream.
+type partsReader struct {
+	io.Reader
+	files []*os.File
+}
+
+func (pr *partsReader) Close() error {
+	var firstErr error
+
+	for _, file := range pr.files {
+		if err := file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// openParts opens a split archive (e.g., dump.archive.001, .002, …) as a
+// single stream. Each pattern is a file name or a glob; a glob’s matches
+// are read in lexical order. Only the first part starts with the magic
+// bytes, so the parts are concatenated without any other processing.
+func openParts(patterns []string) (io.ReadCloser, error) {
+	var paths []string
+
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to expand %#q", pattern)
+		}
+
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no archive parts match %#q", pattern)
+		}
+
+		paths = append(paths, matches...)
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("--parts requires at least one archive part")
+	}
+
+	parts := &partsReader{}
+	readers := make([]io.Reader, 0, len(paths))
+
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			_ = parts.Close()
+			return nil, errors.Wrapf(err, "failed to open %#q", path)
+		}
+
+		parts.files = append(parts.files, file)
+		readers = append(readers, file)
+	}
+
+	parts.Reader = io.MultiReader(readers...)
+
+	return parts, nil
+}
+
+// getInputSource describes where the command reads its archive: a URL,
+// file name(s), or “-” for standard input.
+func getInputSource(cmd *cli.Command) string {
+	if archiveURL := cmd.String("url"); archiveURL != "" {
+		return archiveURL
+	}
+
+	if cmd.Args().Len() == 0 {
+		return "-"
+	}
+
+	return strings.Join(cmd.Args().Slice(), " ")
+}
+
+// setFileInfo records the archive file’s size and modification time in
+// the report.
+func (r *Report) setFileInfo(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return errors.Wrapf(err, "failed to stat %#q", file.Name())
+	}
+
+	size, modTime := info.Size(), info.ModTime()
+	r.FileSize, r.ModTime = &size, &modTime
+
+	return nil
+}
+
+// openArchiveInput opens the command’s input and applies the options
+// that transform the raw stream (e.g., --base64). The caller must close
+// the returned ReadCloser, then read from the returned Reader.
+func openArchiveInput(ctx context.Context, cmd *cli.Command) (io.ReadCloser, io.Reader, error) {
+	input, err := openInput(ctx, cmd)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to open archive")
+	}
+
+	var archiveInput io.Reader = input
+	if cmd.Bool("follow") {
+		archiveInput = newFollowReader(ctx, input, cmd.Duration("follow-timeout"))
+	}
+
+	if cmd.Bool("base64") {
+		// The decoder ignores line breaks, which some encoders insert.
+		archiveInput = base64.NewDecoder(base64.StdEncoding, archiveInput)
+	}
+
+	return input, newContextReader(ctx, archiveInput), nil
+}
+
+// contextReader fails reads once its context is done. Every parse loop
+// reads its input, so this is how cancellation (e.g., --timeout) reaches
+// them.
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func newContextReader(ctx context.Context, reader io.Reader) *contextReader {
+	return &contextReader{ctx: ctx, reader: reader}
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return cr.reader.Read(p)
+}
+
+// openURL streams the response body of an HTTP(S) GET request.
+func openURL(ctx context.Context, archiveURL string) (io.ReadCloser, error) {
+	parsed, err := url.Parse(archiveURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse URL %#q", archiveURL)
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("URL %#q must be http or https, not %#q", archiveURL, parsed.Scheme)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, archiveURL, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create request for %#q", archiveURL)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch %#q", archiveURL)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("failed to fetch %#q: %s", archiveURL, resp.Status)
+	}
+
+	return &urlBody{ReadCloser: resp.Body, size: resp.ContentLength}, nil
+}
+
+// urlBody is an HTTP response body along with its Content-Length, which
+// is -1 if unknown.
+type urlBody struct {
+	io.ReadCloser
+	size int64
+}
+
+// getInputSize returns the input’s size in bytes if it is a regular file
+// or an HTTP response with a Content-Length, else 0.
+func getInputSize(input io.ReadCloser) int64 {
+	switch input := input.(type) {
+	case *os.File:
+		info, err := input.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return 0
+		}
+
+		return info.Size()
+	case *urlBody:
+		return max(input.size, 0)
+	default:
+		return 0
+	}
+}
+
+// followPollInterval is how often followReader checks for new data.
+const followPollInterval = 100 * time.Millisecond
+
+// followReader reads a file that another process (e.g., mongodump) is
+// still writing. Where the underlying reader reports EOF, followReader
+// instead waits for more data, and reports EOF only once idleTimeout
+// passes without any.
+type followReader struct {
+	ctx         context.Context
+	reader      io.Reader
+	idleTimeout time.Duration
+}
+
+func newFollowReader(ctx context.Context, reader io.Reader, idleTimeout time.Duration) *followReader {
+	return &followReader{ctx: ctx, reader: reader, idleTimeout: idleTimeout}
+}
+
+func (fr *followReader) Read(p []byte) (int, error) {
+	deadline := time.Now().Add(fr.idleTimeout)
+
+	for {
+		n, err := fr.reader.Read(p)
+		if !errors.Is(err, io.EOF) {
+			return n, err
+		}
+
+		// More may follow, so this isn’t EOF yet.
+		if n > 0 {
+			return n, nil
+		}
+
+		if time.Now().After(deadline) {
+			return 0, io.EOF
+		}
+
+		select {
+		case <-fr.ctx.Done():
+			return 0, fr.ctx.Err()
+		case <-time.After(followPollInterval):
+		}
+	}
+}
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decompressIfNeeded transparently gunzips input if it’s gzip-compressed,
+// as `mongodump --archive --gzip` output is. It returns the name of the
+// compression, or the empty string if there was none.
+func decompressIfNeeded(input io.Reader) (io.Reader, string, error) {
+	bufInput := bufio.NewReader(input)
+
+	start, err := bufInput.Peek(len(gzipMagic))
+	if err != nil || !bytes.Equal(start, gzipMagic) {
+		// Let the magic-number check report short input.
+		return bufInput, "", nil
+	}
+
+	gzipReader, err := gzip.NewReader(bufInput)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to read gzip header")
+	}
+
+	return gzipReader, "gzip", nil
+}
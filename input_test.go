@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/test.dump" {
+			http.NotFound(w, r)
+			return
+		}
+
+		http.ServeFile(w, r, "test.dump")
+	}))
+	defer server.Close()
+
+	body, err := openURL(context.Background(), server.URL+"/test.dump")
+	require.NoError(t, err, "should fetch dump")
+	defer func() { _ = body.Close() }()
+
+	report, err := getReport(body, os.Stderr, ParseOptions{})
+	require.NoError(t, err, "should parse fetched dump")
+	assert.Len(t, report.CollectionMetadata, 4, "should parse all collection metadata")
+
+	info, err := os.Stat("test.dump")
+	require.NoError(t, err, "should stat dump")
+	assert.Equal(t, info.Size(), getInputSize(body), "should know the fetched size")
+
+	_, err = openURL(context.Background(), server.URL+"/missing.dump")
+	assert.ErrorContains(t, err, "404", "should fail on non-200 response")
+
+	_, err = openURL(context.Background(), "ftp://example.com/test.dump")
+	assert.ErrorContains(t, err, "http or https", "should reject non-HTTP URL")
+}
+
+// growingBuffer simulates a file that another process is appending to.
+type growingBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (gb *growingBuffer) Read(p []byte) (int, error) {
+	gb.mutex.Lock()
+	defer gb.mutex.Unlock()
+
+	return gb.buf.Read(p)
+}
+
+func (gb *growingBuffer) Write(p []byte) (int, error) {
+	gb.mutex.Lock()
+	defer gb.mutex.Unlock()
+
+	return gb.buf.Write(p)
+}
+
+func TestFollowReader(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	growing := &growingBuffer{}
+	go func() {
+		for chunk := range slices.Chunk(dump, 4096) {
+			_, _ = growing.Write(chunk)
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	report, err := getReport(
+		newFollowReader(context.Background(), growing, 500*time.Millisecond),
+		io.Discard,
+		ParseOptions{CountDocuments: true},
+	)
+	require.NoError(t, err, "should parse growing dump")
+	assert.EqualValues(t, len(dump), report.BytesRead, "should read the entire archive")
+	assert.EqualValues(t, 1500, report.DocumentCounts["testDB.testColl"], "should count all documents")
+
+	for _, hashAlgorithm := range []string{"", "sha256"} {
+		start := time.Now()
+		report, err = getReport(
+			newFollowReader(context.Background(), bytes.NewReader(dump), 10*time.Second),
+			io.Discard,
+			ParseOptions{CountDocuments: true, Follow: true, HashAlgorithm: hashAlgorithm},
+		)
+		require.NoError(t, err, "should parse complete dump (hash: %#q)", hashAlgorithm)
+		assert.Less(t, time.Since(start), 5*time.Second, "should stop at end of body, not idle timeout (hash: %#q)", hashAlgorithm)
+		assert.EqualValues(t, len(dump), report.BytesRead, "should read the entire archive (hash: %#q)", hashAlgorithm)
+		assert.True(t, *report.Complete, "archive should be complete (hash: %#q)", hashAlgorithm)
+	}
+
+	_, err = getReport(
+		newFollowReader(context.Background(), bytes.NewReader(dump[:3000]), 50*time.Millisecond),
+		io.Discard,
+		ParseOptions{CountDocuments: true},
+	)
+	assert.ErrorIs(t, err, ErrTruncated, "should give up after idle timeout")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = getReport(
+		newFollowReader(ctx, bytes.NewReader(dump[:3000]), time.Hour),
+		io.Discard,
+		ParseOptions{CountDocuments: true},
+	)
+	assert.ErrorIs(t, err, context.Canceled, "should stop when canceled")
+}
+
+// trickleReader imitates a slow FIFO: each Read returns at most a few
+// bytes, and every other Read returns nothing at all.
+type trickleReader struct {
+	reader io.Reader
+	calls  int
+}
+
+func (tr *trickleReader) Read(p []byte) (int, error) {
+	tr.calls++
+	if tr.calls%2 == 0 {
+		return 0, nil
+	}
+
+	return tr.reader.Read(p[:min(len(p), 1+tr.calls%3)])
+}
+
+func TestTrickleInput(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read test dump")
+
+	gzipped := bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(&gzipped)
+	_, err = gzipWriter.Write(dump)
+	require.NoError(t, err, "should compress test dump")
+	require.NoError(t, gzipWriter.Close(), "should compress test dump")
+
+	opts := ParseOptions{CountDocuments: true, EstimateSizes: true, SampleSize: 1, SchemaDocuments: 5}
+
+	expected, err := getReport(bytes.NewReader(dump), io.Discard, opts)
+	require.NoError(t, err, "should parse dump")
+
+	for name, input := range map[string][]byte{"plain": dump, "gzip": gzipped.Bytes()} {
+		t.Run(name, func(t *testing.T) {
+			report, err := getReport(&trickleReader{reader: bytes.NewReader(input)}, io.Discard, opts)
+			require.NoError(t, err, "short reads should not end the input early")
+
+			report.Compression = expected.Compression
+			assert.Equal(t, expected, report, "short reads should not change the report")
+		})
+	}
+}
+
+func TestSetFileInfo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.dump")
+	require.NoError(t, os.WriteFile(path, []byte("archive"), 0o644), "should write file")
+
+	modTime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	require.NoError(t, os.Chtimes(path, modTime, modTime), "should set mtime")
+
+	file, err := os.Open(path)
+	require.NoError(t, err, "should open file")
+	defer func() { _ = file.Close() }()
+
+	report := Report{}
+	require.NoError(t, report.setFileInfo(file), "should stat file")
+
+	require.NotNil(t, report.FileSize, "should record size")
+	assert.EqualValues(t, 7, *report.FileSize, "should record size")
+	require.NotNil(t, report.ModTime, "should record mtime")
+	assert.True(t, modTime.Equal(*report.ModTime), "should record mtime")
+	assert.EqualValues(t, 7, getInputSize(file), "should know the file’s size")
+	assert.Zero(t, getInputSize(io.NopCloser(os.Stdin)), "should not know standard input’s size")
+
+	doc, err := toDocument(Report{})
+	require.NoError(t, err, "should encode report")
+	for _, key := range []string{"fileSize", "modTime"} {
+		_, err := bsonutil.FindValueByKey(key, &doc)
+		assert.Error(t, err, "should omit %#q without a file", key)
+	}
+}
+
+func TestBase64Input(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read test dump")
+
+	expected, err := getReport(bytes.NewReader(dump), io.Discard, ParseOptions{CountDocuments: true})
+	require.NoError(t, err, "should parse dump")
+
+	// Wrap lines as, e.g., base64(1) does.
+	encoded := base64.StdEncoding.EncodeToString(dump)
+	wrapped := strings.Builder{}
+	for line := range slices.Chunk([]byte(encoded), 76) {
+		wrapped.Write(line)
+		wrapped.WriteString("\r\n")
+	}
+
+	report, err := getReport(
+		base64.NewDecoder(base64.StdEncoding, strings.NewReader(wrapped.String())),
+		io.Discard,
+		ParseOptions{CountDocuments: true},
+	)
+	require.NoError(t, err, "should parse base64-encoded dump")
+	assert.Equal(t, expected, report, "decoding should not change the report")
+
+	_, err = getReport(
+		base64.NewDecoder(base64.StdEncoding, strings.NewReader("not base64!")),
+		io.Discard,
+		ParseOptions{},
+	)
+	assert.ErrorContains(t, err, "illegal base64 data", "should fail on invalid base64")
+}
+
+func TestOpenParts(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump")
+
+	dir := t.TempDir()
+	third := len(dump) / 3
+
+	for i, part := range [][]byte{dump[:third], dump[third : 2*third], dump[2*third:]} {
+		path := filepath.Join(dir, "dump.archive.00"+string(rune('1'+i)))
+		require.NoError(t, os.WriteFile(path, part, 0o644), "should write part")
+	}
+
+	parts, err := openParts([]string{filepath.Join(dir, "dump.archive.*")})
+	require.NoError(t, err, "should open parts")
+	defer func() { _ = parts.Close() }()
+
+	report, err := getReport(parts, os.Stderr, ParseOptions{CountDocuments: true})
+	require.NoError(t, err, "should parse concatenated parts")
+	assert.Len(t, report.CollectionMetadata, 4, "should parse all collection metadata")
+	assert.EqualValues(t, len(dump), report.BytesRead, "should read every part")
+
+	_, err = openParts([]string{filepath.Join(dir, "missing.*")})
+	assert.ErrorContains(t, err, "no archive parts match", "should fail when a glob matches nothing")
+}
+
+func TestContextReader(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump")
+
+	report, err := getReport(newContextReader(context.Background(), bytes.NewReader(dump)), os.Stderr, ParseOptions{})
+	require.NoError(t, err, "should parse with a live context")
+	assert.Len(t, report.CollectionMetadata, 4, "should parse all collection metadata")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+
+	_, err = getReport(newContextReader(ctx, bytes.NewReader(dump)), os.Stderr, ParseOptions{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "should stop once the context expires")
+
+	err = markError(err, ErrTimeout)
+	assert.Equal(t, exitTimeout, exitCode(err), "should exit with the timeout status")
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// These are the --log-format formats.
+const (
+	logFormatLogfmt = "logfmt"
+	logFormatJSON   = "json"
+)
+
+var logFormats = []string{logFormatLogfmt, logFormatJSON}
+
+func validateLogFormat(format string) error {
+	if !slices.Contains(logFormats, format) {
+		return fmt.Errorf("log format must be one of %v, not %#q", logFormats, format)
+	}
+
+	return nil
+}
+
+// getLogFields summarizes a parse of the archive at source for
+// --log-format. parseErr is the parse’s error, if any, in which case
+// report is ignored. crcVerified indicates whether a successful parse
+// verified CRCs.
+func getLogFields(source string, report Report, parseErr error, crcVerified bool) bson.D {
+	fields := bson.D{
+		{Key: "time", Value: time.Now().UTC().Format(time.RFC3339)},
+		{Key: "level", Value: "info"},
+		{Key: "msg", Value: "parsed mongodump archive"},
+		{Key: "source", Value: source},
+	}
+
+	if parseErr != nil {
+		fields[1].Value = "error"
+		fields[2].Value = "failed to parse mongodump archive"
+
+		return append(fields, bson.E{Key: "error", Value: parseErr.Error()})
+	}
+
+	fields = append(
+		fields,
+		bson.E{Key: "databases", Value: report.Summary.Databases},
+		bson.E{Key: "collections", Value: report.Summary.Collections},
+		bson.E{Key: "views", Value: report.Summary.Views},
+	)
+
+	if report.Summary.Documents != nil {
+		fields = append(fields, bson.E{Key: "documents", Value: *report.Summary.Documents})
+	}
+
+	if report.Complete != nil {
+		fields = append(fields, bson.E{Key: "complete", Value: *report.Complete})
+	}
+
+	if crcVerified {
+		fields = append(fields, bson.E{Key: "crcVerified", Value: true})
+	}
+
+	return fields
+}
+
+// writeLogLine writes fields as a single log line in the given format.
+func writeLogLine(out io.Writer, format string, fields bson.D) error {
+	var line []byte
+
+	switch format {
+	case logFormatJSON:
+		json, err := bson.MarshalExtJSON(fields, false, false)
+		if err != nil {
+			return errors.Wrap(err, "failed to encode log line")
+		}
+
+		line = json
+	case logFormatLogfmt:
+		pairs := make([]string, 0, len(fields))
+		for _, field := range fields {
+			pairs = append(pairs, field.Key+"="+formatLogfmtValue(field.Value))
+		}
+
+		line = []byte(strings.Join(pairs, " "))
+	default:
+		return fmt.Errorf("cannot write a log line as %#q", format)
+	}
+
+	_, err := fmt.Fprintf(out, "%s\n", line)
+
+	return errors.Wrap(err, "failed to write log line")
+}
+
+// formatLogfmtValue renders a logfmt value, quoting it if needed.
+func formatLogfmtValue(value any) string {
+	str := fmt.Sprint(value)
+
+	if str == "" || strings.ContainsAny(str, " =\"\\\t\n") {
+		return strconv.Quote(str)
+	}
+
+	return str
+}
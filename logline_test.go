@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteLogLine(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump")
+
+	report, err := getReport(bytes.NewReader(dump), &bytes.Buffer{}, ParseOptions{CountDocuments: true, VerifyCRC: true})
+	require.NoError(t, err, "should parse dump")
+
+	timePattern := `\d{4}-\d\d-\d\dT\d\d:\d\d:\d\dZ`
+
+	buf := bytes.Buffer{}
+	require.NoError(t, writeLogLine(&buf, logFormatLogfmt, getLogFields("my dump", report, nil, true)), "should write logfmt")
+	assert.Regexp(
+		t,
+		regexp.MustCompile(`^time=`+timePattern+` level=info msg="parsed mongodump archive" source="my dump" databases=2 collections=4 views=0 documents=\d+ complete=true crcVerified=true\n$`),
+		buf.String(),
+		"should write logfmt line",
+	)
+
+	buf.Reset()
+	require.NoError(t, writeLogLine(&buf, logFormatJSON, getLogFields("-", Report{}, errors.New("oops"), false)), "should write JSON")
+	assert.Regexp(
+		t,
+		regexp.MustCompile(`^\{"time":"`+timePattern+`","level":"error","msg":"failed to parse mongodump archive","source":"-","error":"oops"\}\n$`),
+		buf.String(),
+		"should write JSON line",
+	)
+}
@@ -8,110 +8,1570 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/mitchellh/go-wordwrap"
 	"github.com/mongodb/mongo-tools/common/archive"
+	"github.com/mongodb/mongo-tools/common/bsonutil"
 	"github.com/pkg/errors"
 	"github.com/urfave/cli/v3"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/term"
 )
 
 const (
 	defaultColumnWidth = 80
+	minColumnWidth     = 20
 )
 
+// defaultMaxCollections is how many collection metadata documents we
+// accept by default. Real archives have far fewer; this guards against
+// crafted ones that would exhaust memory.
+const defaultMaxCollections = 1_000_000
+
+// archiveVersion is the archive format version that mongodump writes.
+const archiveVersion = "0.1"
+
 var terminatorBytes = bytes.Repeat([]byte{0xff}, 4)
 
+// utf8BOM is the UTF-8 byte-order mark, which some storage layers
+// mistakenly prepend to archives.
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// reportVersion is Report.ReportVersion. It increments whenever the
+// report’s encoded shape changes incompatibly (e.g., a field is removed,
+// renamed, or retyped). New fields don’t increment it.
+const reportVersion = 1
+
 type Report struct {
-	Header             bson.D
-	CollectionMetadata []bson.D `bson:"collectionMetadata"`
+	// ReportVersion identifies the report’s schema, so that consumers
+	// can parse defensively. getReport sets it to reportVersion.
+	ReportVersion int `bson:"reportVersion,omitempty"`
+
+	// ExtJSONMode is “relaxed” or “canonical”, per how the report was
+	// encoded as Extended JSON. Other encodings omit it.
+	ExtJSONMode string `bson:"extJsonMode,omitempty"`
+
+	Header             bson.D          `bson:"header,omitempty"`
+	CollectionMetadata []bson.D        `bson:"collectionMetadata"`
+	MetadataErrors     []MetadataError `bson:"metadataErrors,omitempty"`
+	Oplog              OplogInfo
+	BytesRead          int64               `bson:"bytesRead"`
+	CollectionDetails  []CollectionDetails `bson:"collectionDetails,omitempty"`
+	Indexes            []NamespaceIndexes  `bson:"indexes,omitempty"`
+	DocumentCounts     map[string]int64    `bson:"documentCounts,omitempty"`
+	EstimatedSizes     map[string]int64    `bson:"estimatedSizes,omitempty"`
+	CappedOverflows    []CappedOverflow    `bson:"cappedOverflows,omitempty"`
+	GridFSBuckets      []GridFSBucket      `bson:"gridFSBuckets,omitempty"`
+	Blocks             map[string]int      `bson:"blocks,omitempty"`
+	BlockCompression   map[string]string   `bson:"blockCompression,omitempty"`
+	Samples            map[string][]bson.D `bson:"samples,omitempty"`
+	Schemas            map[string]bson.D   `bson:"schemas,omitempty"`
+	FieldStats         map[string]bson.D   `bson:"fieldStats,omitempty"`
+	IDTypes            map[string]bson.D   `bson:"idTypes,omitempty"`
+	HasUsers           bool                `bson:"hasUsers"`
+	HasRoles           bool                `bson:"hasRoles"`
+	AuthDatabases      []DatabaseAuth      `bson:"authDatabases,omitempty"`
+	Summary            Summary             `bson:"summary"`
+	Truncated          bool                `bson:"truncated,omitempty"`
+	Compression        string              `bson:"compression,omitempty"`
+
+	// Complete indicates whether every namespace in the archive ends with
+	// an EOF header, as a fully written archive’s do. It is present only
+	// if the body was read.
+	Complete *bool `bson:"complete,omitempty"`
+
+	// TrailingBytes describes any unexpected data after the archive’s
+	// last namespace. It is present only if the body was read.
+	TrailingBytes *TrailingBytes `bson:"trailingBytes,omitempty"`
+
+	// FeatureCompatibilityVersion is the admin.system.version document
+	// that records the dumped server’s feature compatibility version. It
+	// is present only if the body was read and includes that document.
+	FeatureCompatibilityVersion bson.D `bson:"featureCompatibilityVersion,omitempty"`
+
+	// ConcurrentCollections is the header’s concurrent_collections, i.e.,
+	// how many namespaces’ body blocks the archive may interleave.
+	ConcurrentCollections int `bson:"concurrentCollections,omitempty"`
+
+	// HeaderTypes gives the BSON type of each header field. It is present
+	// only if ParseOptions.HeaderTypes is set.
+	HeaderTypes []HeaderFieldType `bson:"headerTypes,omitempty"`
+
+	InputHash *InputHash `bson:"inputHash,omitempty"`
+
+	// FileSize & ModTime describe the archive file, if the input was one
+	// rather than standard input or a URL.
+	FileSize *int64     `bson:"fileSize,omitempty"`
+	ModTime  *time.Time `bson:"modTime,omitempty"`
+
+	// docBytes holds the per-namespace sizes behind Summary.Bytes, if
+	// any, for reports derived from this one. It isn’t encoded.
+	docBytes map[string]int64
 }
 
-func main() {
-	colWidth, _, err := term.GetSize(int(os.Stdin.Fd()))
+// HeaderMap returns the archive header as a map, for callers that don’t
+// need its field order. It is nil if the report lacks a header.
+func (r Report) HeaderMap() map[string]any {
+	if r.Header == nil {
+		return nil
+	}
+
+	header := make(map[string]any, len(r.Header))
+	for _, elem := range r.Header {
+		header[elem.Key] = elem.Value
+	}
+
+	return header
+}
+
+// MetadataError describes a namespace whose metadata string failed to
+// parse.
+type MetadataError struct {
+	DB         string `bson:"db"`
+	Collection string `bson:"collection"`
+	Error      string `bson:"error"`
+}
+
+// These are the policies for metadata strings that fail to parse.
+const (
+	metadataErrorsIgnore = "ignore"
+	metadataErrorsWarn   = "warn"
+	metadataErrorsFail   = "fail"
+)
+
+var metadataErrorPolicies = []string{metadataErrorsIgnore, metadataErrorsWarn, metadataErrorsFail}
+
+func validateMetadataErrorPolicy(policy string) error {
+	if !slices.Contains(metadataErrorPolicies, policy) {
+		return fmt.Errorf("metadata error policy must be one of %v, not %#q", metadataErrorPolicies, policy)
+	}
+
+	return nil
+}
+
+// ParseOptions control how much of the archive getReport parses.
+type ParseOptions struct {
+	// CountDocuments makes getReport read the archive body in order to
+	// count each namespace’s documents.
+	CountDocuments bool
+
+	// EstimateSizes makes getReport sum each namespace’s document sizes
+	// in order to estimate how much data a restore would write. This
+	// reads only the documents’ length prefixes.
+	EstimateSizes bool
+
+	// MaxDocumentSize is the largest body document that getReport will
+	// accept. Zero means defaultMaxDocumentSize.
+	MaxDocumentSize int
+
+	// DocumentHandler, if non-nil, receives each body document of the
+	// report’s namespaces as raw BSON, in archive order. Callers can
+	// decode it into their own Go types (e.g., with bson.Unmarshal or a
+	// custom registry) in order to build typed extraction pipelines. raw
+	// is valid only during the call. An error stops getReport.
+	DocumentHandler func(ns string, raw bson.Raw) error
+
+	// SampleSize is how many of each namespace’s documents to include
+	// in the report.
+	SampleSize int
+
+	// SampleFields, if nonempty, projects sampled documents to the
+	// given (possibly dotted) field names.
+	SampleFields []string
+
+	// SampleQuery, if nonempty, limits sampled documents to those that
+	// match it. See matchesQuery for the supported query syntax.
+	SampleQuery bson.D
+
+	// SchemaDocuments is how many of each namespace’s documents to
+	// examine in order to infer its schema.
+	SchemaDocuments int
+
+	// FieldStatsDocuments is how many of each namespace’s documents to
+	// examine in order to tally top-level field frequencies.
+	FieldStatsDocuments int
+
+	// CountBlocks makes getReport count how many data blocks each
+	// namespace’s documents are split across. (mongodump interleaves
+	// up to concurrent_collections namespaces’ blocks.)
+	CountBlocks bool
+
+	// CountIDTypes tallies the BSON types of every document’s _id.
+	CountIDTypes bool
+
+	// HeaderTypes makes getReport report each header field’s BSON type.
+	HeaderTypes bool
+
+	// OplogSince, if non-nil, makes getReport count the oplog entries
+	// at or after this timestamp.
+	OplogSince *primitive.Timestamp
+
+	// ShardKeys makes getReport read shard keys from the archive’s
+	// config.collections documents, if any.
+	ShardKeys bool
+
+	// NormalizeIndexes makes getReport list each namespace’s index
+	// specifications in a normalized form that is easy to compare.
+	NormalizeIndexes bool
+
+	// CompactMetadata makes getReport reduce each collection metadata
+	// document’s index specifications to their names. This happens after
+	// the rest of the report is derived from the full specifications.
+	CompactMetadata bool
+
+	// DBs, if nonempty, limits the report to namespaces in the given
+	// databases.
+	DBs []string
+
+	// Collections, if nonempty, limits the report to namespaces with
+	// the given collection names.
+	Collections []string
+
+	// Where, if nonempty, limits the report to namespaces whose metadata
+	// satisfies all of the given expressions (e.g., “capped”,
+	// “indexes>3”, or “type=view”).
+	Where []string
+
+	// SortNamespaces orders the report’s namespaces by database, then
+	// collection. Otherwise they follow the archive’s order.
+	SortNamespaces bool
+
+	// HashAlgorithm, if nonempty, makes getReport compute a digest of the
+	// entire raw input with the named algorithm (e.g., “sha256”).
+	HashAlgorithm string
+
+	// MetadataErrorPolicy says what to do when a namespace’s metadata
+	// string doesn’t parse: metadataErrorsIgnore (the default) warns and
+	// leaves the string as is, metadataErrorsWarn also lists the failure
+	// in the report, and metadataErrorsFail aborts.
+	MetadataErrorPolicy string
+
+	// Strict makes getReport fail on anomalies that it would otherwise
+	// only warn about.
+	Strict bool
+
+	// HeaderOnly makes getReport stop after the archive header. The
+	// returned report contains only the header and what was learned
+	// reading it.
+	HeaderOnly bool
+
+	// MetadataLimit, if positive, limits how many collection metadata
+	// documents getReport reads. This precludes reading the body.
+	MetadataLimit int
+
+	// MaxCollections is the most collection metadata documents that
+	// getReport will accept. Zero means defaultMaxCollections.
+	MaxCollections int
+
+	// CheckComplete makes getReport read the archive body in order to
+	// check that every namespace ends with an EOF header. getReport
+	// checks this whenever it reads the body.
+	CheckComplete bool
+
+	// VerifyCRC makes getReport check each namespace’s documents against
+	// the CRC that the archive records for them.
+	VerifyCRC bool
+
+	// KeepCredentials disables the redaction of credentials from
+	// sampled system.users documents.
+	KeepCredentials bool
+
+	// ProgressOut, if non-nil, receives periodic progress updates.
+	// ProgressTotal, if positive, is the input’s size, which lets those
+	// updates include a percentage and an estimated time remaining.
+	ProgressOut   io.Writer
+	ProgressTotal int64
+
+	// ExplainOut, if non-nil, receives a narration of each parsing step
+	// along with the offset where it began. ExplainColor colorizes it.
+	ExplainOut   io.Writer
+	ExplainColor bool
+
+	// WarningSummaryOut, if non-nil, receives a count of each category
+	// of warning once parsing ends, even if it ends in failure.
+	WarningSummaryOut io.Writer
+
+	// SkipBytes is how many bytes of junk (e.g., a byte-order mark)
+	// precede the archive. getReport discards them unread.
+	SkipBytes int
+
+	// MagicNumber overrides the archive magic number that getReport
+	// expects. Zero means archive.MagicNumber.
+	MagicNumber uint32
+
+	// Follow says that the input is still being written (e.g., with
+	// --follow), so that reading past the archive’s end would wait for
+	// data that may never come. getReport instead stops reading the body
+	// once every namespace has its EOF header.
+	Follow bool
+}
+
+func (opts ParseOptions) magicNumber() uint32 {
+	if opts.MagicNumber == 0 {
+		return archive.MagicNumber
+	}
+
+	return opts.MagicNumber
+}
+
+func (opts ParseOptions) namespaceFilter() (namespaceFilter, error) {
+	predicates, err := parseWheres(opts.Where)
 	if err != nil {
-		colWidth = defaultColumnWidth
+		return namespaceFilter{}, err
+	}
+
+	return namespaceFilter{dbs: opts.DBs, colls: opts.Collections, predicates: predicates}, nil
+}
+
+func (opts ParseOptions) readsBody() bool {
+	return opts.CountDocuments ||
+		opts.EstimateSizes ||
+		opts.SampleSize > 0 ||
+		opts.SchemaDocuments > 0 ||
+		opts.FieldStatsDocuments > 0 ||
+		opts.CountBlocks ||
+		opts.CountIDTypes ||
+		opts.ShardKeys ||
+		opts.VerifyCRC ||
+		opts.CheckComplete ||
+		opts.OplogSince != nil ||
+		opts.DocumentHandler != nil
+}
+
+func (opts ParseOptions) maxCollections() int {
+	if opts.MaxCollections == 0 {
+		return defaultMaxCollections
+	}
+
+	return opts.MaxCollections
+}
+
+// progressTotal returns how many bytes the counting reader will read, or
+// 0 if unknown. ProgressTotal counts the raw input, so it says nothing
+// about the size of decompressed input.
+func (opts ParseOptions) progressTotal(compression string) int64 {
+	if compression != "" || opts.ProgressTotal <= int64(opts.SkipBytes) {
+		return 0
+	}
+
+	return opts.ProgressTotal - int64(opts.SkipBytes)
+}
+
+func (opts ParseOptions) maxDocumentSize() int {
+	if opts.MaxDocumentSize == 0 {
+		return defaultMaxDocumentSize
+	}
+
+	return opts.MaxDocumentSize
+}
+
+var exitStatusHelp = fmt.Sprintf(
+	"Exit status is %d if the input is not a mongodump archive, %d if the archive is truncated or corrupt, %d if a CRC check fails, %d if --timeout expires, or %d for any other failure.",
+	exitBadMagic,
+	exitCorrupt,
+	exitCRCMismatch,
+	exitTimeout,
+	exitFailure,
+)
+
+var description = "This tool reads a mongodump archive from the given file or standard input, parses its header, then outputs the parse to standard output in MongoDB Extended JSON. This lets you see an archive’s contents without actually restoring it.\n\nGiven multiple files, it parses them concurrently and outputs a JSON array with each file’s report (or error), ordered by file name. With --parts, it instead reads the files as consecutive parts of one split archive.\n\n" + exitStatusHelp
+
+// getColumnWidth returns the width to wrap output to: flagWidth if
+// nonzero, else $COLUMNS, else the terminal’s width. Standard input is
+// usually the archive, so we check standard output’s terminal first.
+func getColumnWidth(flagWidth int64) int {
+	if flagWidth != 0 {
+		return int(flagWidth)
+	}
+
+	if cols, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && cols >= minColumnWidth {
+		return cols
+	}
+
+	for _, file := range []*os.File{os.Stdout, os.Stdin} {
+		if cols, _, err := term.GetSize(int(file.Fd())); err == nil {
+			return cols
+		}
+	}
+
+	return defaultColumnWidth
+}
+
+func validateWidth(width int64) error {
+	if width != 0 && width < minColumnWidth {
+		return fmt.Errorf("width must be at least %d, not %d", minColumnWidth, width)
+	}
+
+	return nil
+}
+
+func main() {
+	// Wrapping depends on --width, which isn’t parsed until just before
+	// help is printed.
+	printHelp := cli.HelpPrinter
+	cli.HelpPrinter = func(out io.Writer, template string, data any) {
+		if cmd, ok := data.(*cli.Command); ok && cmd.Root() == cmd {
+			cmd.Description = wordwrap.WrapString(description, uint(getColumnWidth(cmd.Int("width"))-4))
+		}
+
+		printHelp(out, template, data)
 	}
 
 	var cmd = cli.Command{
 		Name:        "mongodump-parser",
 		Usage:       "parse mongodump archive files",
-		Description: wordwrap.WrapString("This tool reads a mongodump archive file from standard input, parses its header, then outputs the parse to standard output in MongoDB Extended JSON. This lets you see an archive’s contents without actually restoring it.", uint(colWidth-4)),
-		Action: func(_ context.Context, cmd *cli.Command) error {
-			return run(cmd)
+		ArgsUsage:   "[FILE...]",
+		Description: description,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "quiet",
+				Usage: "suppress warnings and progress output",
+			},
+			&cli.StringFlag{
+				Name:      "warnings-to",
+				Usage:     "send warnings to `DEST`: stdout, stderr, or file:PATH (appended to)",
+				Value:     warningsToStderr,
+				Validator: validateWarningsTo,
+			},
+			&cli.BoolFlag{
+				Name:  "fail-on-empty",
+				Usage: "fail if the archive contains no namespaces or, if documents are counted or sized, no documents",
+			},
+			&cli.StringFlag{
+				Name:      "expect-namespaces",
+				Usage:     "fail if the archive lacks any namespace listed, one DB.COLLECTION per line, in `FILE`",
+				TakesFile: true,
+			},
+			&cli.BoolFlag{
+				Name:  "exact",
+				Usage: "with --expect-namespaces, also fail if the archive has namespaces that FILE doesn’t list",
+			},
+			&cli.BoolFlag{
+				Name:  "pretty-errors",
+				Usage: "once parsing ends, summarize the warnings by category (wherever warnings go)",
+			},
+			&cli.BoolFlag{
+				Name:  "strict",
+				Usage: "fail on anything that would otherwise be a warning",
+			},
+			&cli.StringFlag{
+				Name:      "format",
+				Usage:     fmt.Sprintf("output `FORMAT` (one of: %s)", strings.Join(formats, ", ")),
+				Value:     formatJSON,
+				Validator: validateFormat,
+			},
+			&cli.StringFlag{
+				Name:      "color",
+				Usage:     fmt.Sprintf("colorize table and --explain output `WHEN` (one of: %s; auto honors $NO_COLOR)", strings.Join(colorModes, ", ")),
+				Value:     colorAuto,
+				Validator: validateColorMode,
+			},
+			&cli.BoolFlag{
+				Name:  "no-color",
+				Usage: "same as --color=never",
+			},
+			&cli.IntFlag{
+				Name:      "parallel",
+				Usage:     "given multiple archive files, parse up to `N` at once",
+				Value:     int64(runtime.NumCPU()),
+				Validator: validateParallel,
+			},
+			&cli.BoolFlag{
+				Name:  "stream",
+				Usage: "write collection metadata as it’s read, using constant memory (compact JSON only; omits fields that need the whole archive)",
+			},
+			&cli.BoolFlag{
+				Name:  "pretty",
+				Usage: "pretty-print JSON output",
+			},
+			&cli.BoolFlag{
+				Name:  "pretty-metadata",
+				Usage: "indent each namespace’s metadata even when the rest of the JSON output is compact",
+			},
+			&cli.IntFlag{
+				Name:      "indent",
+				Usage:     "indent pretty-printed JSON by `N` spaces per level",
+				Value:     defaultIndent,
+				Validator: validateIndent,
+			},
+			&cli.BoolFlag{
+				Name:  "canonical",
+				Usage: "write canonical rather than relaxed Extended JSON, which preserves numeric types exactly",
+			},
+			&cli.BoolFlag{
+				Name:  "escape-html",
+				Usage: "escape <, >, and & in JSON strings (e.g., for embedding in HTML)",
+			},
+			&cli.StringFlag{
+				Name:  "split-by-db",
+				Usage: "rather than output one report, write a report per database into `DIR` (e.g., DIR/mydb.json)",
+			},
+			&cli.IntFlag{
+				Name:      "width",
+				Usage:     "wrap help text to `N` columns (default: $COLUMNS or the terminal’s width)",
+				Validator: validateWidth,
+			},
+			&cli.BoolFlag{
+				Name:  "count",
+				Usage: "count each namespace’s documents (requires reading the entire archive)",
+			},
+			&cli.BoolFlag{
+				Name:  "verify-crc",
+				Usage: "verify each namespace’s CRC (requires reading the entire archive)",
+			},
+			&cli.BoolFlag{
+				Name:  "check-complete",
+				Usage: "check that every namespace ends with an EOF header (requires reading the entire archive)",
+			},
+			&cli.StringFlag{
+				Name:  "baseline",
+				Usage: "rather than the report, output which namespaces were added, removed, or changed since the report saved in `FILE` (generate both with the same flags)",
+			},
+			&cli.StringFlag{
+				Name:  "count-docs",
+				Usage: "output only the number of documents in namespace `DB.COLLECTION`",
+			},
+			&cli.BoolFlag{
+				Name:  "estimate",
+				Usage: "estimate each namespace’s restored data size from document lengths (requires reading the entire archive)",
+			},
+			&cli.IntFlag{
+				Name:      "max-collections",
+				Usage:     "fail if the archive has more than `N` collection metadata documents",
+				Value:     defaultMaxCollections,
+				Validator: validateMaxCollections,
+			},
+			&cli.IntFlag{
+				Name:      "max-doc-size",
+				Usage:     "fail if any document exceeds `BYTES` in size",
+				Value:     defaultMaxDocumentSize,
+				Validator: validateMaxDocumentSize,
+			},
+			&cli.IntFlag{
+				Name:      "sample",
+				Usage:     "include the first `N` documents of each namespace",
+				Validator: validateDocumentLimit,
+			},
+			&cli.StringFlag{
+				Name:      "query",
+				Usage:     "sample only documents matching `QUERY`, an Extended JSON document of top-level field conditions: values to equal, or {\"$eq\": value} or {\"$in\": [values]}",
+				Validator: validateQueryString,
+			},
+			&cli.StringFlag{
+				Name:  "fields",
+				Usage: "project sampled documents to the given comma-separated `FIELDS` (e.g., a,b.c)",
+			},
+			&cli.IntFlag{
+				Name:      "infer-schema",
+				Usage:     "infer each namespace’s schema from its first `N` documents",
+				Validator: validateDocumentLimit,
+			},
+			&cli.IntFlag{
+				Name:      "field-stats",
+				Usage:     "tally top-level field frequencies in each namespace’s first `N` documents",
+				Validator: validateDocumentLimit,
+			},
+			&cli.BoolFlag{
+				Name:  "id-types",
+				Usage: "tally the BSON types of each namespace’s _id values (decodes every document)",
+			},
+			&cli.BoolFlag{
+				Name:  "count-blocks",
+				Usage: "count how many data blocks each namespace’s documents are split across",
+			},
+			&cli.BoolFlag{
+				Name:  "shard-keys",
+				Usage: "report shard keys from the archive’s config.collections, if any",
+			},
+			&cli.StringFlag{
+				Name:      "since",
+				Usage:     "count the oplog entries at or after `TIMESTAMP`, either SECONDS[:ORDINAL] or an RFC 3339 time (requires reading the entire archive)",
+				Validator: validateOplogTimestamp,
+			},
+			&cli.BoolFlag{
+				Name:  "header-types",
+				Usage: "report the BSON type of each archive header field (for debugging header encoding)",
+			},
+			&cli.BoolFlag{
+				Name:  "normalize-indexes",
+				Usage: "list each namespace’s index specifications in a normalized, comparable form",
+			},
+			&cli.BoolFlag{
+				Name:  "explain",
+				Usage: "narrate each parsing step, with its offset, to stderr",
+			},
+			&cli.BoolFlag{
+				Name:  "compact-metadata",
+				Usage: "reduce index specifications in collection metadata to their names",
+			},
+			&cli.BoolFlag{
+				Name:  "redact-credentials",
+				Usage: "remove credentials from sampled system.users documents",
+				Value: true,
+			},
+			&cli.BoolFlag{
+				Name:  "roundtrip-check",
+				Usage: "fail unless the report survives conversion to Extended JSON and back",
+			},
+			&cli.StringFlag{
+				Name:      "magic",
+				Usage:     "expect `NUMBER` (hex or decimal) as the archive’s magic number",
+				Hidden:    true,
+				Validator: validateMagicNumber,
+			},
+			&cli.IntFlag{
+				Name:      "skip",
+				Usage:     "skip `N` bytes of junk (e.g., a byte-order mark) before the archive",
+				Validator: validateSkipBytes,
+			},
+			&cli.IntFlag{
+				Name:      "head",
+				Usage:     "stop after the first `N` collection metadata documents",
+				Validator: validateDocumentLimit,
+			},
+			&cli.BoolFlag{
+				Name:  "only-header",
+				Usage: "output only the archive header, and stop reading after it",
+			},
+			&cli.BoolFlag{
+				Name:  "include-header",
+				Usage: "include the archive header in the report",
+				Value: true,
+			},
+			&cli.BoolFlag{
+				Name:  "sort",
+				Usage: "sort namespaces by database, then collection, rather than archive order",
+			},
+			&cli.StringSliceFlag{
+				Name:  "db",
+				Usage: "report only namespaces in database `NAME` (repeatable)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "collection",
+				Usage: "report only namespaces with collection name `NAME` (repeatable)",
+			},
+			&cli.StringSliceFlag{
+				Name:      "where",
+				Usage:     fmt.Sprintf("report only namespaces whose metadata satisfies `EXPR`, e.g., capped, !capped, indexes>3, or type=view (repeatable; properties: %s)", strings.Join(whereProperties(), ", ")),
+				Validator: validateWheres,
+			},
+			&cli.StringFlag{
+				Name: "metadata-errors",
+				Usage: fmt.Sprintf(
+					"when collection metadata fails to parse, `POLICY` (one of: %s) says whether to just warn, also list the failures in the report, or fail",
+					strings.Join(metadataErrorPolicies, ", "),
+				),
+				Value:     metadataErrorsIgnore,
+				Validator: validateMetadataErrorPolicy,
+			},
+			&cli.StringFlag{
+				Name:      "hash",
+				Usage:     fmt.Sprintf("report a digest of the entire input using `ALGORITHM` (one of: %s)", strings.Join(hashAlgorithmNames(), ", ")),
+				Validator: validateHashAlgorithm,
+			},
+			&cli.StringFlag{
+				Name:      "log-format",
+				Usage:     fmt.Sprintf("also write a one-line summary of the parse, for log pipelines, to standard error in `FORMAT` (one of: %s)", strings.Join(logFormats, ", ")),
+				Validator: validateLogFormat,
+			},
+			&cli.BoolFlag{
+				Name:  "timing",
+				Usage: "print parse time and throughput to standard error",
+			},
+			&cli.BoolFlag{
+				Name:  "base64",
+				Usage: "decode base64-encoded input",
+			},
+			&cli.BoolFlag{
+				Name:  "parts",
+				Usage: "read the files (or globs, e.g. 'dump.archive.*') as consecutive parts of one split archive",
+			},
+			&cli.BoolFlag{
+				Name:  "follow",
+				Usage: "at end of input, wait for more data (e.g., while mongodump is still writing the archive)",
+			},
+			&cli.DurationFlag{
+				Name:  "follow-timeout",
+				Usage: "with --follow, end input after `DURATION` without new data",
+				Value: 30 * time.Second,
+			},
+			&cli.DurationFlag{
+				Name:      "timeout",
+				Usage:     "fail if parsing takes longer than `DURATION` (0 means no limit)",
+				Validator: validateTimeout,
+			},
+			&cli.StringFlag{
+				Name:  "url",
+				Usage: "read the archive from an HTTP(S) `URL` rather than a file or standard input",
+			},
+		},
+		Commands: []*cli.Command{
+			{
+				Name:      "header",
+				Usage:     "output the archive header’s raw BSON",
+				ArgsUsage: "[FILE]",
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					return withTimeout(ctx, cmd, runHeader)
+				},
+			},
+			{
+				Name:      "diff",
+				Usage:     "list the namespaces that were added, removed, or changed between two archives",
+				ArgsUsage: "OLD NEW",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "diff-ignore-order",
+						Usage: "treat collection metadata that differs only in field order as unchanged",
+					},
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					return withTimeout(ctx, cmd, runDiff)
+				},
+			},
+			{
+				Name:   "selftest",
+				Usage:  "parse a synthesized archive to check that the parser works",
+				Hidden: true,
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					return withTimeout(ctx, cmd, runSelfTest)
+				},
+			},
+		},
+		Before: openWarningsFile,
+		After:  closeWarningsFile,
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			return withTimeout(ctx, cmd, run)
 		},
 	}
 
 	if err := cmd.Run(context.Background(), os.Args); err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
-func run(cmd *cli.Command) error {
-	report, err := getReport(os.Stdin, os.Stderr)
+func validateTimeout(timeout time.Duration) error {
+	if timeout < 0 {
+		return fmt.Errorf("timeout must not be negative, not %s", timeout)
+	}
+
+	return nil
+}
+
+// withTimeout calls action with a context that --timeout, if given,
+// limits. If the limit is exceeded, the error is marked with ErrTimeout.
+func withTimeout(
+	ctx context.Context,
+	cmd *cli.Command,
+	action func(context.Context, *cli.Command) error,
+) error {
+	timeout := cmd.Duration("timeout")
+	if timeout == 0 {
+		return action(ctx, cmd)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := action(ctx, cmd)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return markError(errors.Wrapf(err, "timed out after %s", timeout), ErrTimeout)
+	}
+
+	return err
+}
+
+func run(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() > 1 && !cmd.Bool("parts") {
+		return runMulti(ctx, cmd)
+	}
+
+	input, archiveInput, err := openArchiveInput(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = input.Close() }()
+
+	parseOpts, warnOut, err := getParseOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	// Base64 and a growing file both make the raw size meaningless.
+	if parseOpts.ProgressOut != nil && !cmd.Bool("base64") && !cmd.Bool("follow") {
+		parseOpts.ProgressTotal = getInputSize(input)
+	}
+
+	var expectedNamespaces []string
+	if manifest := cmd.String("expect-namespaces"); manifest != "" {
+		expectedNamespaces, err = readNamespaceManifest(manifest)
+		if err != nil {
+			return err
+		}
+	} else if cmd.Bool("exact") {
+		return fmt.Errorf("--exact requires --expect-namespaces")
+	}
+
+	if parseOpts.CompactMetadata && cmd.String("format") == formatIndexes {
+		return fmt.Errorf("--compact-metadata cannot be combined with --format %s", formatIndexes)
+	}
+
+	if cmd.String("baseline") != "" {
+		if format := cmd.String("format"); format != formatJSON {
+			return fmt.Errorf("--baseline cannot write %#q", format)
+		}
+
+		for _, name := range []string{"only-header", "split-by-db"} {
+			if cmd.IsSet(name) {
+				return fmt.Errorf("--baseline cannot be combined with --%s", name)
+			}
+		}
+	}
+
+	if cmd.Bool("stream") {
+		for _, name := range streamConflicts {
+			if cmd.IsSet(name) {
+				return fmt.Errorf("--stream cannot be combined with --%s", name)
+			}
+		}
+
+		if format := cmd.String("format"); format != formatJSON {
+			return fmt.Errorf("--stream cannot write %#q", format)
+		}
+
+		return streamReport(
+			archiveInput,
+			os.Stdout,
+			warnOut,
+			parseOpts,
+			outputOptions{format: formatJSON, escapeHTML: cmd.Bool("escape-html"), canonical: cmd.Bool("canonical")},
+		)
+	}
+
+	if ns := cmd.String("count-docs"); ns != "" {
+		count, err := countNamespaceDocuments(archiveInput, warnOut, ns, parseOpts)
+		if err != nil {
+			return errors.Wrap(err, "failed to parse archive")
+		}
+
+		_, err = fmt.Fprintln(os.Stdout, count)
+
+		return errors.Wrap(err, "failed to output document count")
+	}
+
+	start := time.Now()
+
+	report, err := getReport(archiveInput, warnOut, parseOpts)
+
+	if logFormat := cmd.String("log-format"); logFormat != "" {
+		logErr := writeLogLine(os.Stderr, logFormat, getLogFields(getInputSource(cmd), report, err, parseOpts.VerifyCRC))
+		if err == nil && logErr != nil {
+			return logErr
+		}
+	}
+
 	if err != nil {
 		return errors.Wrap(err, "failed to parse archive")
 	}
 
-	json, err := bson.MarshalExtJSON(report, false, false)
+	if cmd.Bool("timing") {
+		_, _ = fmt.Fprintln(os.Stderr, formatTiming(report.BytesRead, time.Since(start)))
+	}
+
+	if cmd.Bool("fail-on-empty") {
+		err := report.Summary.checkNotEmpty()
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.String("expect-namespaces") != "" {
+		err := checkExpectedNamespaces(report.CollectionMetadata, expectedNamespaces, cmd.Bool("exact"))
+		if err != nil {
+			return err
+		}
+	}
+
+	if path := cmd.String("baseline"); path != "" {
+		baseline, err := readBaselineMetadata(path)
+		if err != nil {
+			return err
+		}
+
+		diff, err := getBaselineDiff(baseline, report.CollectionMetadata)
+		if err != nil {
+			return err
+		}
+
+		return writeMetadataDiff(os.Stdout, diff, cmd)
+	}
+
+	if file, ok := input.(*os.File); ok {
+		err := report.setFileInfo(file)
+		if err != nil {
+			return err
+		}
+	}
+
+	opts := outputOptions{
+		format:         cmd.String("format"),
+		pretty:         cmd.Bool("pretty"),
+		prettyMetadata: cmd.Bool("pretty-metadata"),
+		indent:         int(cmd.Int("indent")),
+		escapeHTML:     cmd.Bool("escape-html"),
+		canonical:      cmd.Bool("canonical"),
+
+		// Split reports go to files, which aren’t terminals.
+		color: getColorMode(cmd) == colorAlways,
+	}
+
+	if cmd.Bool("only-header") {
+		if !cmd.Bool("include-header") {
+			return fmt.Errorf("--only-header and --include-header=false are contradictory")
+		}
+
+		return writeHeader(os.Stdout, report.Header, opts)
+	}
+
+	if !cmd.Bool("include-header") {
+		report.Header = nil
+	}
+
+	if dir := cmd.String("split-by-db"); dir != "" {
+		return writeSplitReports(dir, report, opts)
+	}
+
+	if cmd.Bool("roundtrip-check") {
+		err := checkRoundtrip(report)
+		if err != nil {
+			return err
+		}
+	}
+
+	opts.color = useColor(getColorMode(cmd), os.Stdout)
+
+	return writeReport(os.Stdout, report, opts)
+}
+
+// runHeader implements the header subcommand.
+func runHeader(ctx context.Context, cmd *cli.Command) error {
+	input, archiveInput, err := openArchiveInput(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = input.Close() }()
+
+	warnOut := getWarningsOut(cmd)
+
+	magicNumber, err := getMagicNumber(cmd, getExplainOut(cmd))
 	if err != nil {
-		return errors.Wrap(err, "failed to encode archive report")
+		return err
 	}
 
-	_, err = io.Copy(os.Stdout, bytes.NewBuffer(json))
+	header, err := getRawHeader(archiveInput, warnOut, ParseOptions{
+		SkipBytes:   int(cmd.Int("skip")),
+		MagicNumber: magicNumber,
+	})
 	if err != nil {
-		return errors.Wrap(err, "failed to output report")
+		return errors.Wrap(err, "failed to parse archive")
+	}
+
+	_, err = os.Stdout.Write(header)
+	if err != nil {
+		return errors.Wrap(err, "failed to output archive header")
 	}
 
 	return nil
 }
 
-func getReport(input io.Reader, errOut io.Writer) (Report, error) {
-	err := checkMagicBytes(input)
+// getParseOptions returns the ParseOptions that the command’s flags
+// specify, along with where warnings should go.
+func getParseOptions(cmd *cli.Command) (ParseOptions, io.Writer, error) {
+	warnOut := getWarningsOut(cmd)
+	var progressOut io.Writer
+	if !cmd.Bool("quiet") && term.IsTerminal(int(os.Stderr.Fd())) && !cmd.Bool("explain") {
+		progressOut = os.Stderr
+	}
+
+	explainOut := getExplainOut(cmd)
+
+	var warningSummaryOut io.Writer
+	if cmd.Bool("pretty-errors") {
+		warningSummaryOut = warnOut
+	}
+
+	magicNumber, err := getMagicNumber(cmd, explainOut)
 	if err != nil {
-		return Report{}, errors.Wrap(err, "this does not appear to be a mongodump archive")
+		return ParseOptions{}, nil, err
 	}
 
-	header := bson.D{}
+	var oplogSince *primitive.Timestamp
+	if sinceStr := cmd.String("since"); sinceStr != "" {
+		since, err := parseOplogTimestamp(sinceStr)
+		if err != nil {
+			return ParseOptions{}, nil, err
+		}
+
+		oplogSince = &since
+	}
+
+	var sampleQuery bson.D
+	if queryStr := cmd.String("query"); queryStr != "" {
+		if cmd.Int("sample") == 0 {
+			return ParseOptions{}, nil, fmt.Errorf("--query requires --sample")
+		}
+
+		sampleQuery, err = parseQuery(queryStr)
+		if err != nil {
+			return ParseOptions{}, nil, err
+		}
+	}
+
+	parseOpts := ParseOptions{
+		CountDocuments:      cmd.Bool("count"),
+		EstimateSizes:       cmd.Bool("estimate"),
+		MaxDocumentSize:     int(cmd.Int("max-doc-size")),
+		MaxCollections:      int(cmd.Int("max-collections")),
+		SampleSize:          int(cmd.Int("sample")),
+		SampleFields:        parseFieldList(cmd.String("fields")),
+		SampleQuery:         sampleQuery,
+		SchemaDocuments:     int(cmd.Int("infer-schema")),
+		FieldStatsDocuments: int(cmd.Int("field-stats")),
+		CountIDTypes:        cmd.Bool("id-types"),
+		CountBlocks:         cmd.Bool("count-blocks"),
+		ShardKeys:           cmd.Bool("shard-keys"),
+		HeaderTypes:         cmd.Bool("header-types"),
+		OplogSince:          oplogSince,
+		NormalizeIndexes:    cmd.Bool("normalize-indexes"),
+		CompactMetadata:     cmd.Bool("compact-metadata"),
+		VerifyCRC:           cmd.Bool("verify-crc"),
+		CheckComplete:       cmd.Bool("check-complete"),
+		KeepCredentials:     !cmd.Bool("redact-credentials"),
+		ProgressOut:         progressOut,
+		ExplainOut:          explainOut,
+		ExplainColor:        useColor(getColorMode(cmd), os.Stderr),
+		WarningSummaryOut:   warningSummaryOut,
+		MagicNumber:         magicNumber,
+		SkipBytes:           int(cmd.Int("skip")),
+		MetadataLimit:       int(cmd.Int("head")),
+		DBs:                 cmd.StringSlice("db"),
+		Collections:         cmd.StringSlice("collection"),
+		Where:               cmd.StringSlice("where"),
+		HeaderOnly:          cmd.Bool("only-header"),
+		SortNamespaces:      cmd.Bool("sort"),
+		HashAlgorithm:       cmd.String("hash"),
+		Strict:              cmd.Bool("strict"),
+		MetadataErrorPolicy: cmd.String("metadata-errors"),
+		Follow:              cmd.Bool("follow"),
+	}
+
+	return parseOpts, warnOut, nil
+}
+
+// getExplainOut returns where --explain narrates parsing, or nil without
+// it.
+func getExplainOut(cmd *cli.Command) io.Writer {
+	if !cmd.Bool("explain") {
+		return nil
+	}
+
+	return os.Stderr
+}
+
+// getMagicNumber returns the --magic number, or 0 if there is none. If
+// explainOut is non-nil (i.e., with --explain), it notes the override
+// there.
+func getMagicNumber(cmd *cli.Command, explainOut io.Writer) (uint32, error) {
+	magicStr := cmd.String("magic")
+	if magicStr == "" {
+		return 0, nil
+	}
+
+	magicNumber, err := parseMagicNumber(magicStr)
+	if err != nil {
+		return 0, err
+	}
+
+	if explainOut != nil {
+		_, _ = fmt.Fprintf(
+			explainOut,
+			"expecting magic number %#x rather than %#x\n",
+			magicNumber,
+			archive.MagicNumber,
+		)
+	}
+
+	return magicNumber, nil
+}
+
+// getRawHeader returns the archive header exactly as the archive encodes
+// it. Of opts, it honors only SkipBytes & MagicNumber.
+func getRawHeader(input io.Reader, errOut io.Writer, opts ParseOptions) (bson.Raw, error) {
+	input, _, err := openArchive(input, errOut, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkMagicBytes(input, opts.magicNumber())
+	if err != nil {
+		return nil, errors.Wrap(err, "this does not appear to be a mongodump archive")
+	}
+
+	header := bson.Raw{}
 	err = readBSON(input, &header)
 	if err != nil {
-		return Report{}, errors.Wrap(err, "failed to read archive header")
+		return nil, markError(
+			errors.Wrap(err, "failed to read archive header"),
+			ErrBadHeader,
+		)
+	}
+
+	return header, nil
+}
+
+// openArchive skips any leading junk in input, then decompresses it if
+// needed. It returns the name of the compression, if any.
+func openArchive(input io.Reader, errOut io.Writer, opts ParseOptions) (io.Reader, string, error) {
+	if opts.SkipBytes > 0 {
+		_, err := io.CopyN(io.Discard, input, int64(opts.SkipBytes))
+		if err != nil {
+			return nil, "", markIfTruncated(errors.Wrapf(err, "failed to skip %d leading bytes", opts.SkipBytes))
+		}
+
+		_, _ = fmt.Fprintf(errOut, "skipped %d bytes before the archive\n", opts.SkipBytes)
+	}
+
+	return decompressIfNeeded(input)
+}
+
+func getReport(input io.Reader, errOut io.Writer, opts ParseOptions) (Report, error) {
+	if opts.HashAlgorithm != "" {
+		return getHashedReport(input, errOut, opts)
+	}
+
+	if opts.MetadataLimit > 0 && opts.readsBody() {
+		return Report{}, fmt.Errorf("cannot read archive body when limiting collection metadata")
+	}
+
+	if opts.HeaderOnly && (opts.MetadataLimit > 0 || opts.readsBody()) {
+		return Report{}, fmt.Errorf("cannot read past the archive header when reading only the header")
+	}
+
+	if err := validateQuery(opts.SampleQuery); err != nil {
+		return Report{}, err
+	}
+
+	input, compression, err := openArchive(input, errOut, opts)
+	if err != nil {
+		return Report{}, err
+	}
+
+	// The counting reader sits atop the buffer so that read-ahead
+	// doesn’t count toward BytesRead.
+	cr := newCountingReader(bufio.NewReader(input))
+	cr.explainOut, cr.explainColor = opts.ExplainOut, opts.ExplainColor
+
+	if opts.ProgressOut != nil {
+		cr.progress = newProgressReporter(opts.ProgressOut, opts.progressTotal(compression))
+		defer cr.progress.finish()
+	}
+
+	if compression != "" {
+		cr.explain(0, "decompressing %s input; offsets are into the decompressed archive", compression)
+	}
+
+	header, err := readHeader(cr, opts)
+	if err != nil {
+		return Report{}, err
+	}
+
+	var headerTypes []HeaderFieldType
+	if opts.HeaderTypes {
+		headerTypes = getHeaderFieldTypes(header)
+	}
+
+	if opts.HeaderOnly {
+		return Report{
+			ReportVersion:         reportVersion,
+			Header:                header,
+			BytesRead:             cr.BytesRead(),
+			Compression:           compression,
+			ConcurrentCollections: getConcurrentCollections(header),
+			HeaderTypes:           headerTypes,
+		}, nil
 	}
 
-	bufInput := bufio.NewReader(input)
+	warner := newWarner(errOut, opts.Strict)
+	defer warner.writeSummary(opts.WarningSummaryOut)
 
-	mdDocs, err := getCollectionMetadata(bufInput, errOut)
+	err = checkArchiveVersion(header, warner)
+	if err != nil {
+		return Report{}, err
+	}
+
+	metadata, err := getCollectionMetadata(cr, warner, opts)
 	if err != nil {
 		return Report{}, errors.Wrap(err, "failed to read collection metadata")
 	}
 
-	// TODO: We could optionally count documents per namespace and
-	// extract the CRC, if we want.
+	mdDocs := metadata.docs
 
-	return Report{
+	filter, err := opts.namespaceFilter()
+	if err != nil {
+		return Report{}, err
+	}
+
+	// The oplog and auth data concern the archive as a whole, so we
+	// check for them in the unfiltered metadata.
+	report := Report{
+		ReportVersion:      reportVersion,
 		Header:             header,
-		CollectionMetadata: mdDocs,
-	}, nil
+		CollectionMetadata: filterMetadata(mdDocs, filter),
+		Oplog:              getOplogInfo(mdDocs),
+		HasUsers:           hasNamespace(mdDocs, "admin", "system.users"),
+		HasRoles:           hasNamespace(mdDocs, "admin", "system.roles"),
+		AuthDatabases:      getDatabaseAuth(mdDocs),
+		Truncated:          metadata.truncated,
+		Compression:        compression,
+	}
+
+	report.ConcurrentCollections = getConcurrentCollections(header)
+	report.HeaderTypes = headerTypes
+
+	if opts.SortNamespaces {
+		sortMetadata(report.CollectionMetadata)
+	}
+
+	if opts.MetadataErrorPolicy == metadataErrorsWarn {
+		report.MetadataErrors = slices.DeleteFunc(metadata.parseErrors, func(mdErr MetadataError) bool {
+			return !hasNamespace(report.CollectionMetadata, mdErr.DB, mdErr.Collection)
+		})
+	}
+
+	matchesNS := filter.namespaceMatcher(report.CollectionMetadata)
+
+	if report.HasUsers {
+		warner.notice(warnAuthData, "archive contains users (admin.system.users)")
+	}
+
+	if report.HasRoles {
+		warner.notice(warnAuthData, "archive contains roles (admin.system.roles)")
+	}
+
+	for _, auth := range report.AuthDatabases {
+		if auth.DB != "admin" {
+			warner.notice(warnAuthData, "archive contains users or roles of database %#q", auth.DB)
+		}
+	}
+
+	var docBytes map[string]int64
+	var shardKeys map[string]bson.D
+
+	if opts.readsBody() {
+		sampler := newSampler(opts)
+		schemaInferrer := newSchemaInferrer(opts.SchemaDocuments)
+		fieldStatsCounter := newFieldStatsCounter(opts.FieldStatsDocuments)
+		idTypeCounter := newIDTypeCounter(opts.CountIDTypes)
+		shardKeyCollector := newShardKeyCollector(opts.ShardKeys)
+		fcvCollector := &fcvCollector{}
+		oplogAnalyzer := &oplogAnalyzer{since: opts.OplogSince}
+		documentHandler := documentHandler{handle: opts.DocumentHandler, matches: matchesNS}
+
+		stats, err := readBody(
+			cr,
+			opts,
+			mdDocs,
+			[]documentAnalyzer{
+				sampler,
+				schemaInferrer,
+				fieldStatsCounter,
+				idTypeCounter,
+				shardKeyCollector,
+				fcvCollector,
+				oplogAnalyzer,
+				documentHandler,
+			},
+		)
+		if err != nil {
+			return Report{}, errors.Wrap(err, "failed to read archive body")
+		}
+
+		if report.ConcurrentCollections > 0 && stats.maxOpenNamespaces > report.ConcurrentCollections {
+			err := warner.warn(
+				warnConcurrency,
+				"archive had %d namespaces open at once, but its header allows only %d concurrent collections",
+				stats.maxOpenNamespaces,
+				report.ConcurrentCollections,
+			)
+			if err != nil {
+				return Report{}, err
+			}
+		}
+
+		if stats.trailing != nil {
+			report.TrailingBytes = stats.trailing
+
+			err := warner.warn(
+				warnTrailingData,
+				"archive has %d unexpected bytes after its end (at offset %d)",
+				stats.trailing.Length,
+				stats.trailing.Offset,
+			)
+			if err != nil {
+				return Report{}, err
+			}
+		}
+
+		if isCompressionMixed(stats.compression) {
+			err := warner.warn(warnMixedCompression, "archive mixes gzip-compressed and uncompressed body blocks")
+			if err != nil {
+				return Report{}, err
+			}
+		}
+
+		// Completeness concerns the archive as a whole, so we check
+		// the unfiltered metadata and stats.
+		unended := getUnendedNamespaces(mdDocs, stats)
+		complete := len(unended) == 0
+		report.Complete = &complete
+
+		if !complete {
+			err := warner.warn(warnIncomplete, "archive lacks EOF headers for %v; it may be truncated", unended)
+			if err != nil {
+				return Report{}, err
+			}
+		}
+
+		// Analyzers such as shardKeyCollector need to see namespaces
+		// that the filter excludes, so we filter their results instead.
+		filterNamespaceMap(stats.docCounts, matchesNS)
+		filterNamespaceMap(stats.docBytes, matchesNS)
+		filterNamespaceMap(stats.blocks, matchesNS)
+		filterNamespaceMap(stats.compression, matchesNS)
+		filterNamespaceMap(sampler.samples, matchesNS)
+
+		if opts.CountDocuments {
+			report.DocumentCounts = stats.docCounts
+			docBytes = stats.docBytes
+		}
+
+		if opts.EstimateSizes {
+			report.EstimatedSizes = stats.docBytes
+			docBytes = stats.docBytes
+		}
+
+		if opts.CountBlocks {
+			report.Blocks = stats.blocks
+		}
+
+		// Uncompressed blocks are the norm, so we report compression
+		// only if some block was compressed.
+		if hasCompressedBlocks(stats.compression) {
+			report.BlockCompression = stats.compression
+		}
+
+		if opts.SampleSize > 0 {
+			report.Samples = sampler.samples
+		}
+
+		if opts.SchemaDocuments > 0 {
+			report.Schemas = schemaInferrer.results()
+			filterNamespaceMap(report.Schemas, matchesNS)
+		}
+
+		if opts.FieldStatsDocuments > 0 {
+			report.FieldStats = fieldStatsCounter.results()
+			filterNamespaceMap(report.FieldStats, matchesNS)
+		}
+
+		if opts.CountIDTypes {
+			report.IDTypes = idTypeCounter.results()
+			filterNamespaceMap(report.IDTypes, matchesNS)
+		}
+
+		shardKeys = shardKeyCollector.keys
+		report.FeatureCompatibilityVersion = fcvCollector.doc
+
+		oplogAnalyzer.setOplogInfo(&report.Oplog)
+
+		if opts.OplogSince != nil {
+			report.Oplog.Since = opts.OplogSince
+
+			if !report.Oplog.Present {
+				_, _ = fmt.Fprintln(errOut, "archive has no oplog, so there are no oplog entries to count")
+			}
+		}
+	}
+
+	report.CollectionDetails = getCollectionDetails(report.CollectionMetadata, shardKeys)
+	if opts.NormalizeIndexes {
+		report.Indexes = getNamespaceIndexes(report.CollectionMetadata)
+	}
+	report.BytesRead = cr.BytesRead()
+	report.docBytes = docBytes
+	report.Summary = getSummary(report.CollectionMetadata, report.DocumentCounts, docBytes)
+	report.GridFSBuckets = getGridFSBuckets(report.CollectionMetadata, report.DocumentCounts, docBytes)
+
+	if docBytes != nil {
+		report.CappedOverflows = getCappedOverflows(report.CollectionMetadata, report.DocumentCounts, docBytes)
+
+		for _, overflow := range report.CappedOverflows {
+			err := warner.warn(warnCappedOverflow, "%s; restore will drop documents", overflow.describe())
+			if err != nil {
+				return Report{}, err
+			}
+		}
+	}
+
+	if opts.CompactMetadata {
+		for i, mdDoc := range report.CollectionMetadata {
+			report.CollectionMetadata[i] = compactMetadata(mdDoc)
+		}
+	}
+
+	return report, nil
 }
 
-func getCollectionMetadata(bufInput *bufio.Reader, errOut io.Writer) ([]bson.D, error) {
-	mdDocs := []bson.D{}
+// readHeader reads the magic number and the archive header.
+func readHeader(cr *countingReader, opts ParseOptions) (bson.D, error) {
+	offset := cr.BytesRead()
+
+	err := checkMagicBytes(cr, opts.magicNumber())
+	if err != nil {
+		return nil, errors.Wrap(err, "this does not appear to be a mongodump archive")
+	}
+
+	cr.explain(offset, "read magic bytes (OK)")
+	offset = cr.BytesRead()
+
+	header := bson.D{}
+	err = readBSON(cr, &header)
+	if err != nil {
+		return nil, markError(
+			errors.Wrap(err, "failed to read archive header"),
+			ErrBadHeader,
+		)
+	}
+
+	version, _ := bsonutil.FindStringValueByKey("version", &header)
+	cr.explain(offset, "read header (version %s, %d bytes)", version, cr.BytesRead()-offset)
+
+	return header, nil
+}
+
+// checkArchiveVersion warns if the archive header’s format version isn’t
+// the one that this tool understands.
+func checkArchiveVersion(header bson.D, warner *warner) error {
+	if version, _ := bsonutil.FindStringValueByKey("version", &header); version != archiveVersion {
+		return warner.warn(warnArchiveVersion, "archive format version is %#q; this tool understands %#q", version, archiveVersion)
+	}
+
+	return nil
+}
+
+// collectionMetadata is what getCollectionMetadata reads.
+type collectionMetadata struct {
+	// docs is empty if readCollectionMetadata read the documents.
+	docs []bson.D
+
+	// count is how many documents were read.
+	count int
+
+	// truncated indicates that the limit stopped reading early.
+	truncated bool
+
+	// parseErrors lists the metadata strings that failed to parse.
+	parseErrors []MetadataError
+}
+
+// getCollectionMetadata reads the collection metadata documents. If
+// opts.MetadataLimit is positive, this stops after that many documents and
+// indicates whether any remained. opts.MetadataErrorPolicy governs
+// metadata strings that fail to parse.
+func getCollectionMetadata(
+	bufInput *countingReader,
+	warner *warner,
+	opts ParseOptions,
+) (collectionMetadata, error) {
+	docs := []bson.D{}
+
+	metadata, err := readCollectionMetadata(bufInput, warner, opts, func(mdDoc bson.D) error {
+		docs = append(docs, mdDoc)
+		return nil
+	})
+	metadata.docs = docs
+
+	return metadata, err
+}
+
+// readCollectionMetadata is like getCollectionMetadata but, rather than
+// retain the documents, passes each to visit as soon as it’s read.
+func readCollectionMetadata(
+	bufInput *countingReader,
+	warner *warner,
+	opts ParseOptions,
+	visit func(mdDoc bson.D) error,
+) (collectionMetadata, error) {
+	metadata := collectionMetadata{}
+	seen := map[string]bool{}
+	scratch := bsonBuffer{}
 
 	for {
 		next4, err := bufInput.Peek(4)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to check for end of collection metadata")
+			return collectionMetadata{}, markIfTruncated(
+				errors.Wrap(err, "failed to check for end of collection metadata"),
+			)
 		}
 		if bytes.Equal(next4, terminatorBytes) {
+			bufInput.explain(bufInput.BytesRead(), "reached metadata terminator")
+
+			// Consume the terminator so that the stream is positioned
+			// at the archive body.
+			_, err := bufInput.Discard(len(terminatorBytes))
+			if err != nil {
+				return collectionMetadata{}, errors.Wrap(err, "failed to read collection metadata terminator")
+			}
+
 			break
 		}
 
+		if opts.MetadataLimit > 0 && metadata.count == opts.MetadataLimit {
+			metadata.truncated = true
+			return metadata, nil
+		}
+
+		if metadata.count == opts.maxCollections() {
+			return collectionMetadata{}, fmt.Errorf(
+				"archive has more than %d collection metadata documents (see --max-collections)",
+				opts.maxCollections(),
+			)
+		}
+
+		offset := bufInput.BytesRead()
+
 		mdDoc := bson.D{}
-		err = readBSON(bufInput, &mdDoc)
+		err = readBSONBuffered(bufInput, &scratch, &mdDoc)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to read collection metadata document")
+			return collectionMetadata{}, markError(
+				errors.Wrap(err, "failed to read collection metadata document"),
+				ErrMetadataParse,
+			)
+		}
+
+		if bufInput.explainOut != nil {
+			db, coll := getNamespace(mdDoc)
+			bufInput.explain(
+				offset,
+				"read metadata doc %d: %s.%s (%d bytes)",
+				metadata.count+1, db, coll, bufInput.BytesRead()-offset,
+			)
 		}
 
 		for i := range mdDoc {
@@ -119,49 +1579,180 @@ func getCollectionMetadata(bufInput *bufio.Reader, errOut io.Writer) ([]bson.D,
 				continue
 			}
 
-			mdStr, ok := mdDoc[i].Value.(string)
-			if !ok {
-				return nil, errors.Wrapf(err, "expected collection metadata to be %T, not %T (%v)", mdStr, mdDoc[i].Value, mdDoc)
+			var mdStr string
+
+			switch value := mdDoc[i].Value.(type) {
+			case string:
+				mdStr = value
+			case bson.D:
+				// Some archive variants embed the metadata directly
+				// rather than as Extended JSON.
+				continue
+			default:
+				return collectionMetadata{}, markError(
+					errors.Errorf("expected collection metadata to be %T or %T, not %T (%v)", mdStr, bson.D{}, mdDoc[i].Value, mdDoc),
+					ErrMetadataParse,
+				)
 			}
 
 			parsedMetadata := bson.D{}
 			err := bson.UnmarshalExtJSON([]byte(mdStr), false, &parsedMetadata)
 			if err != nil {
-				_, _ = fmt.Fprintf(
-					errOut,
-					"failed to parse collection metadata string: %v",
-					err,
+				db, coll := getNamespace(mdDoc)
+				if opts.MetadataErrorPolicy == metadataErrorsFail {
+					return collectionMetadata{}, markError(
+						errors.Wrapf(err, "failed to parse collection metadata string for %s.%s", db, coll),
+						ErrMetadataParse,
+					)
+				}
+
+				warnErr := warner.warn(warnMetadataParse, "failed to parse collection metadata string for %s.%s: %v", db, coll, err)
+				if warnErr != nil {
+					return collectionMetadata{}, markError(warnErr, ErrMetadataParse)
+				}
+
+				metadata.parseErrors = append(
+					metadata.parseErrors,
+					MetadataError{DB: db, Collection: coll, Error: err.Error()},
 				)
 			} else {
 				mdDoc[i].Value = parsedMetadata
 			}
 		}
 
-		mdDocs = append(mdDocs, mdDoc)
+		if err := validateNamespace(mdDoc); err != nil {
+			err := warner.warn(warnInvalidNamespace, "%v", err)
+			if err != nil {
+				return collectionMetadata{}, err
+			}
+		}
+
+		db, coll := getNamespace(mdDoc)
+		if ns := db + "." + coll; seen[ns] {
+			err := warner.warn(warnDuplicateNamespace, "collection metadata lists %#q more than once", ns)
+			if err != nil {
+				return collectionMetadata{}, err
+			}
+		} else {
+			seen[ns] = true
+		}
+
+		err = visit(mdDoc)
+		if err != nil {
+			return collectionMetadata{}, err
+		}
+
+		metadata.count++
+		bufInput.progress.addNamespace()
 	}
 
-	return mdDocs, nil
+	return metadata, nil
 }
 
-func checkMagicBytes(input io.Reader) error {
+func checkMagicBytes(input io.Reader, expected uint32) error {
 	magicBytes := [4]byte{}
 	_, err := io.ReadFull(input, magicBytes[:])
 	if err != nil {
-		return errors.Wrap(err, "failed to read archive magic bytes")
+		return markIfTruncated(errors.Wrap(err, "failed to read archive magic bytes"))
 	}
 
 	magicNum := binary.LittleEndian.Uint32(magicBytes[:])
-	if magicNum != archive.MagicNumber {
-		return fmt.Errorf("unexpected magic number header (%v, %d); should be %d", magicBytes, magicNum, archive.MagicNumber)
+	if magicNum != expected && bytes.HasPrefix(magicBytes[:], utf8BOM) {
+		return markError(
+			fmt.Errorf("not a mongodump archive (found a UTF-8 byte-order mark; try --skip %d)", len(utf8BOM)),
+			ErrBadMagic,
+		)
+	}
+
+	if magicNum != expected {
+		return markError(
+			fmt.Errorf("unexpected magic number header (%v, %d); should be %d", magicBytes, magicNum, expected),
+			ErrBadMagic,
+		)
+	}
+
+	return nil
+}
+
+func validateMaxCollections(limit int64) error {
+	if limit < 1 {
+		return fmt.Errorf("maximum collections must be positive, not %d", limit)
+	}
+
+	return nil
+}
+
+func validateSkipBytes(skip int64) error {
+	if skip < 0 {
+		return fmt.Errorf("bytes to skip must be non-negative, not %d", skip)
 	}
 
 	return nil
 }
 
+// parseMagicNumber parses a hex (0x-prefixed) or decimal magic number.
+func parseMagicNumber(magicStr string) (uint32, error) {
+	magicNum, err := strconv.ParseUint(magicStr, 0, 32)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid magic number %#q", magicStr)
+	}
+
+	if magicNum == 0 {
+		return 0, fmt.Errorf("magic number must be nonzero")
+	}
+
+	return uint32(magicNum), nil
+}
+
+func validateMagicNumber(magicStr string) error {
+	_, err := parseMagicNumber(magicStr)
+	return err
+}
+
+// getConcurrentCollections returns the archive header’s
+// concurrent_collections, or 0 if that is missing.
+func getConcurrentCollections(header bson.D) int {
+	concurrency, _ := bsonutil.FindIntByKey("concurrent_collections", &header)
+
+	return concurrency
+}
+
+// readBSON reads one BSON document from rdr and decodes it into target.
 func readBSON[T any](rdr io.Reader, target *T) error {
-	raw, err := bson.ReadDocument(rdr)
+	return readBSONBuffered(rdr, &bsonBuffer{}, target)
+}
+
+// bsonBuffer is scratch space for readBSONBuffered. Reusing one across a
+// parse loop avoids allocating a byte slice for every document.
+type bsonBuffer struct {
+	bytes []byte
+}
+
+// readBSONBuffered is like readBSON but reads the document into scratch,
+// which it grows as needed. The decoded target doesn’t alias scratch.
+func readBSONBuffered[T any](rdr io.Reader, scratch *bsonBuffer, target *T) error {
+	var lengthBytes [4]byte
+
+	_, err := io.ReadFull(rdr, lengthBytes[:])
+	if err != nil {
+		return markIfTruncated(errors.Wrap(err, "failed to read BSON document"))
+	}
+
+	docLen := int(int32(binary.LittleEndian.Uint32(lengthBytes[:])))
+	if docLen < minDocumentSize {
+		return markError(fmt.Errorf("invalid BSON document length (%d)", docLen), ErrCorrupt)
+	}
+
+	if cap(scratch.bytes) < docLen {
+		scratch.bytes = make([]byte, docLen)
+	}
+
+	raw := scratch.bytes[:docLen]
+	copy(raw, lengthBytes[:])
+
+	_, err = io.ReadFull(rdr, raw[len(lengthBytes):])
 	if err != nil {
-		return errors.Wrap(err, "failed to read BSON document")
+		return markIfTruncated(errors.Wrap(err, "failed to read BSON document"))
 	}
 
 	docPtr := new(T)
@@ -1,16 +1,30 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"maps"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 
+	"github.com/mongodb/mongo-tools/common/archive"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 const dumpExtJSON = `
 {
+  "reportVersion": 1,
   "header": {
     "concurrent_collections": 4,
     "version": "0.1",
@@ -116,7 +130,27 @@ const dumpExtJSON = `
       "size": 0,
       "type": "collection"
     }
-  ]
+  ],
+  "oplog": {
+    "present": false
+  },
+  "bytesRead": 1448,
+  "hasUsers": true,
+  "hasRoles": true,
+  "authDatabases": [
+    {
+      "db": "admin",
+      "users": true,
+      "roles": true
+    }
+  ],
+  "summary": {
+    "databases": 2,
+    "collections": 4,
+    "views": 0,
+    "indexes": 6
+  },
+  "concurrentCollections": 4
 }
 `
 
@@ -132,8 +166,737 @@ func TestReport(t *testing.T) {
 	file, err := os.Open("test.dump")
 	require.NoError(t, err, "should open dump file")
 
-	report, err := getReport(file, os.Stderr)
+	report, err := getReport(file, os.Stderr, ParseOptions{})
 	require.NoError(t, err, "should parse dump")
 
 	assert.Equal(t, expectReport, report, "should get expected report")
 }
+
+func TestOplogInfo(t *testing.T) {
+	assert.Equal(
+		t,
+		OplogInfo{Present: true},
+		getOplogInfo([]bson.D{
+			makeMetadataDoc("testDB", "testColl"),
+			makeMetadataDoc("", "oplog"),
+		}),
+		"should detect mongodump’s oplog namespace",
+	)
+
+	assert.Equal(
+		t,
+		OplogInfo{},
+		getOplogInfo([]bson.D{
+			makeMetadataDoc("testDB", "oplog"),
+		}),
+		"should ignore non-oplog namespaces",
+	)
+}
+
+func TestOplogEntries(t *testing.T) {
+	entry := func(t, i uint32) bson.D {
+		return bson.D{{Key: "ts", Value: primitive.Timestamp{T: t, I: i}}, {Key: "op", Value: "n"}}
+	}
+
+	dump := makeArchive(
+		t,
+		bson.D{},
+		[]bson.D{makeMetadataDoc("db", "coll"), makeMetadataDoc("", "oplog")},
+		[]testBlock{
+			{db: "db", coll: "coll", docs: []bson.D{{{Key: "ts", Value: primitive.Timestamp{T: 50}}}}},
+			{db: "", coll: "oplog", docs: []bson.D{entry(100, 2), entry(100, 1)}},
+			{db: "", coll: "oplog", docs: []bson.D{entry(300, 1), {{Key: "op", Value: "n"}}}},
+			{db: "db", coll: "coll", eof: true},
+			{db: "", coll: "oplog", eof: true},
+		},
+	)
+
+	report, err := getReport(bytes.NewReader(dump), io.Discard, ParseOptions{CountDocuments: true})
+	require.NoError(t, err, "should parse archive")
+
+	entries := int64(4)
+	assert.Equal(
+		t,
+		OplogInfo{
+			Present: true,
+			Entries: &entries,
+			FirstTS: &primitive.Timestamp{T: 100, I: 1},
+			LastTS:  &primitive.Timestamp{T: 300, I: 1},
+		},
+		report.Oplog,
+		"should describe only the oplog’s entries",
+	)
+
+	// An unknown BSON type after “ts” makes each entry undecodable, so
+	// this shows that tallying the oplog doesn’t decode it.
+	undecodable := bytes.ReplaceAll(dump, []byte("\x02op\x00"), []byte("\x99op\x00"))
+
+	report, err = getReport(bytes.NewReader(undecodable), io.Discard, ParseOptions{CountDocuments: true})
+	require.NoError(t, err, "should parse archive without decoding the oplog")
+	assert.EqualValues(t, 4, *report.Oplog.Entries, "should count entries from raw BSON")
+	assert.Equal(t, &primitive.Timestamp{T: 300, I: 1}, report.Oplog.LastTS, "should read ts from raw BSON")
+
+	_, err = getReport(bytes.NewReader(undecodable), io.Discard, ParseOptions{SampleSize: 1})
+	assert.ErrorIs(t, err, ErrCorrupt, "sampling should decode the oplog")
+
+	report, err = getReport(bytes.NewReader(dump), io.Discard, ParseOptions{})
+	require.NoError(t, err, "should parse archive")
+	assert.Equal(t, OplogInfo{Present: true}, report.Oplog, "should describe entries only when reading the body")
+
+	noOplog := makeArchive(t, bson.D{}, []bson.D{makeMetadataDoc("db", "coll")}, nil)
+	report, err = getReport(bytes.NewReader(noOplog), io.Discard, ParseOptions{CountDocuments: true})
+	require.NoError(t, err, "should parse archive")
+	assert.Equal(t, OplogInfo{}, report.Oplog, "should omit entries without an oplog")
+}
+
+func TestOplogSince(t *testing.T) {
+	entry := func(t, i uint32) bson.D {
+		return bson.D{{Key: "ts", Value: primitive.Timestamp{T: t, I: i}}, {Key: "op", Value: "n"}}
+	}
+
+	dump := makeArchive(
+		t,
+		bson.D{},
+		[]bson.D{makeMetadataDoc("db", "coll"), makeMetadataDoc("", "oplog")},
+		[]testBlock{
+			{db: "db", coll: "coll", docs: []bson.D{{{Key: "ts", Value: primitive.Timestamp{T: 500}}}}},
+			{db: "", coll: "oplog", docs: []bson.D{entry(100, 1), entry(200, 1), entry(200, 2)}},
+			{db: "", coll: "oplog", docs: []bson.D{entry(300, 1)}},
+			{db: "db", coll: "coll", eof: true},
+			{db: "", coll: "oplog", eof: true},
+		},
+	)
+
+	since, err := parseOplogTimestamp("200:2")
+	require.NoError(t, err, "should parse timestamp")
+
+	report, err := getReport(bytes.NewReader(dump), io.Discard, ParseOptions{OplogSince: &since})
+	require.NoError(t, err, "should parse archive")
+
+	assert.EqualValues(t, 2, *report.Oplog.EntriesSince, "should count later entries")
+	assert.Equal(t, &since, report.Oplog.Since, "should report --since")
+
+	noOplog := makeArchive(t, bson.D{}, []bson.D{makeMetadataDoc("db", "coll")}, nil)
+
+	warnings := bytes.Buffer{}
+	report, err = getReport(bytes.NewReader(noOplog), &warnings, ParseOptions{OplogSince: &since})
+	require.NoError(t, err, "should parse archive")
+	assert.Nil(t, report.Oplog.EntriesSince, "should not count without an oplog")
+	assert.Contains(t, warnings.String(), "archive has no oplog", "should say there’s no oplog")
+}
+
+func TestParseOplogTimestamp(t *testing.T) {
+	for str, expected := range map[string]primitive.Timestamp{
+		"1700000000":           {T: 1700000000},
+		"1700000000:7":         {T: 1700000000, I: 7},
+		"2023-11-14T22:13:20Z": {T: 1700000000},
+	} {
+		ts, err := parseOplogTimestamp(str)
+		require.NoError(t, err, "should parse %#q", str)
+		assert.Equal(t, expected, ts, "should parse %#q", str)
+	}
+
+	for _, str := range []string{"", "yesterday", "1:x", "-5", "99999999999"} {
+		_, err := parseOplogTimestamp(str)
+		assert.Error(t, err, "should reject %#q", str)
+	}
+}
+
+func TestReportHead(t *testing.T) {
+	for _, limit := range []int{1, 3} {
+		file, err := os.Open("test.dump")
+		require.NoError(t, err, "should open dump file")
+
+		report, err := getReport(file, os.Stderr, ParseOptions{MetadataLimit: limit})
+		require.NoError(t, err, "should parse dump")
+		_ = file.Close()
+
+		assert.Len(t, report.CollectionMetadata, limit, "should stop after %d docs", limit)
+		assert.True(t, report.Truncated, "should mark report truncated")
+	}
+
+	file, err := os.Open("test.dump")
+	require.NoError(t, err, "should open dump file")
+	defer func() { _ = file.Close() }()
+
+	report, err := getReport(file, os.Stderr, ParseOptions{MetadataLimit: 4})
+	require.NoError(t, err, "should parse dump")
+	assert.Len(t, report.CollectionMetadata, 4, "should read all docs")
+	assert.False(t, report.Truncated, "should not mark complete report truncated")
+}
+
+func TestReportHeaderOnly(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	// Everything after the header is cut off, so this fails if getReport
+	// tries to read further.
+	headerEnd := 4 + int(binary.LittleEndian.Uint32(dump[4:]))
+
+	report, err := getReport(bytes.NewReader(dump[:headerEnd]), os.Stderr, ParseOptions{HeaderOnly: true})
+	require.NoError(t, err, "should parse header")
+
+	assert.Equal(t, getTestReport(t).Header, report.Header, "should read header")
+	assert.Nil(t, report.CollectionMetadata, "should not read collection metadata")
+	assert.EqualValues(t, headerEnd, report.BytesRead, "should stop after header")
+	assert.Equal(t, 4, report.ConcurrentCollections, "should read concurrency from header")
+
+	_, err = getReport(bytes.NewReader(dump), os.Stderr, ParseOptions{HeaderOnly: true, CountDocuments: true})
+	assert.Error(t, err, "should reject reading the body")
+}
+
+func TestReportHeaderMap(t *testing.T) {
+	report := getTestReport(t)
+
+	assert.Equal(
+		t,
+		map[string]any{
+			"concurrent_collections": int32(4),
+			"version":                "0.1",
+			"server_version":         "8.0.3-120-gbc35ab4",
+			"tool_version":           "100.7.1",
+		},
+		report.HeaderMap(),
+		"should map header fields",
+	)
+	assert.Nil(t, Report{}.HeaderMap(), "should be nil without a header")
+}
+
+func TestFeatureCompatibilityVersion(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	report, err := getReport(bytes.NewReader(dump), io.Discard, ParseOptions{CountDocuments: true, DBs: []string{"testDB"}})
+	require.NoError(t, err, "should parse dump")
+	assert.Equal(
+		t,
+		bson.D{{Key: "_id", Value: "featureCompatibilityVersion"}, {Key: "version", Value: "8.0"}},
+		report.FeatureCompatibilityVersion,
+		"should find FCV even when filtering out admin",
+	)
+
+	report, err = getReport(bytes.NewReader(dump), io.Discard, ParseOptions{})
+	require.NoError(t, err, "should parse dump")
+	assert.Nil(t, report.FeatureCompatibilityVersion, "should omit FCV without reading the body")
+}
+
+func TestExplain(t *testing.T) {
+	dump := makeArchive(
+		t,
+		bson.D{},
+		[]bson.D{makeMetadataDocWithOptions("db", "coll", bson.D{})},
+		[]testBlock{
+			{db: "db", coll: "coll", docs: []bson.D{{{Key: "a", Value: int32(1)}}}},
+			{db: "db", coll: "coll", eof: true},
+		},
+	)
+
+	explanation := bytes.Buffer{}
+	_, err := getReport(bytes.NewReader(dump), io.Discard, ParseOptions{CountDocuments: true, ExplainOut: &explanation})
+	require.NoError(t, err, "should parse archive")
+
+	lines := strings.Split(strings.TrimSuffix(explanation.String(), "\n"), "\n")
+	require.Len(t, lines, 7, "should narrate each step: %s", explanation.String())
+
+	assert.Equal(t, "[offset 0] read magic bytes (OK)", lines[0], "should narrate magic bytes")
+	assert.Regexp(t, `^\[offset 4\] read header \(version 0\.1, \d+ bytes\)$`, lines[1], "should narrate header")
+	assert.Regexp(t, `^\[offset \d+\] read metadata doc 1: db\.coll \(\d+ bytes\)$`, lines[2], "should narrate metadata")
+	assert.Regexp(t, `^\[offset \d+\] reached metadata terminator$`, lines[3], "should narrate terminator")
+	assert.Regexp(t, `^\[offset \d+\] read block for db\.coll: 1 documents \(\d+ bytes\)$`, lines[4], "should narrate block")
+	assert.Regexp(t, `^\[offset \d+\] read EOF for db\.coll \(CRC -?\d+\)$`, lines[5], "should narrate EOF")
+	assert.Equal(t, fmt.Sprintf("[offset %d] reached end of archive", len(dump)), lines[6], "should narrate end")
+}
+
+func TestNamespaceFilter(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	cases := []struct {
+		dbs, colls []string
+		expected   []string
+	}{
+		{nil, nil, []string{"testDB.testColl", "admin.system.users", "admin.system.roles", "admin.system.version"}},
+		{[]string{"admin"}, nil, []string{"admin.system.users", "admin.system.roles", "admin.system.version"}},
+		{nil, []string{"system.users", "testColl"}, []string{"testDB.testColl", "admin.system.users"}},
+		{[]string{"admin"}, []string{"system.users", "testColl"}, []string{"admin.system.users"}},
+		{[]string{"nonexistent"}, nil, []string{}},
+	}
+
+	for _, c := range cases {
+		report, err := getReport(
+			bytes.NewReader(dump),
+			io.Discard,
+			ParseOptions{DBs: c.dbs, Collections: c.colls, CountDocuments: true},
+		)
+		require.NoError(t, err, "should parse dump (dbs=%v, colls=%v)", c.dbs, c.colls)
+
+		namespaces := []string{}
+		for _, mdDoc := range report.CollectionMetadata {
+			db, coll := getNamespace(mdDoc)
+			namespaces = append(namespaces, db+"."+coll)
+		}
+		assert.Equal(t, c.expected, namespaces, "metadata (dbs=%v, colls=%v)", c.dbs, c.colls)
+		assert.ElementsMatch(t, c.expected, slices.Collect(maps.Keys(report.DocumentCounts)), "counts (dbs=%v, colls=%v)", c.dbs, c.colls)
+		assert.Equal(t, len(c.expected), report.Summary.Collections, "summary (dbs=%v, colls=%v)", c.dbs, c.colls)
+		assert.True(t, report.HasUsers, "auth detection should ignore filter (dbs=%v, colls=%v)", c.dbs, c.colls)
+	}
+}
+
+func TestSortNamespaces(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	for sorted, expected := range map[bool][]string{
+		false: {"testDB.testColl", "admin.system.users", "admin.system.roles", "admin.system.version"},
+		true:  {"admin.system.roles", "admin.system.users", "admin.system.version", "testDB.testColl"},
+	} {
+		report, err := getReport(bytes.NewReader(dump), io.Discard, ParseOptions{SortNamespaces: sorted})
+		require.NoError(t, err, "should parse dump")
+
+		namespaces := []string{}
+		for _, mdDoc := range report.CollectionMetadata {
+			db, coll := getNamespace(mdDoc)
+			namespaces = append(namespaces, db+"."+coll)
+		}
+		assert.Equal(t, expected, namespaces, "sorted=%t", sorted)
+	}
+}
+
+func TestDocumentMetadata(t *testing.T) {
+	metadata := bson.D{
+		{Key: "options", Value: bson.D{{Key: "capped", Value: true}}},
+		{Key: "indexes", Value: bson.A{}},
+	}
+	rawMetadata, err := bson.Marshal(metadata)
+	require.NoError(t, err, "should marshal metadata")
+
+	// makeArchive converts bson.D metadata to Extended JSON, so we pass
+	// raw BSON to get an embedded document.
+	dump := makeArchive(
+		t,
+		bson.D{},
+		[]bson.D{
+			append(makeMetadataDoc("db", "coll"), bson.E{Key: "metadata", Value: bson.Raw(rawMetadata)}),
+		},
+		nil,
+	)
+
+	report, err := getReport(bytes.NewReader(dump), io.Discard, ParseOptions{})
+	require.NoError(t, err, "should parse archive")
+	require.Len(t, report.CollectionMetadata, 1, "should read metadata")
+
+	parsed, ok := getParsedMetadata(report.CollectionMetadata[0])
+	require.True(t, ok, "metadata should be a document")
+	assert.Equal(t, metadata, parsed, "should use embedded metadata as is")
+	assert.True(t, isCapped(report.CollectionMetadata[0]), "should read options from embedded metadata")
+
+	dump = makeArchive(
+		t,
+		bson.D{},
+		[]bson.D{
+			append(makeMetadataDoc("db", "coll"), bson.E{Key: "metadata", Value: int32(1)}),
+		},
+		nil,
+	)
+
+	_, err = getReport(bytes.NewReader(dump), io.Discard, ParseOptions{})
+	assert.ErrorIs(t, err, ErrMetadataParse, "should reject other metadata types")
+}
+
+func TestDuplicateNamespaces(t *testing.T) {
+	dump := makeArchive(
+		t,
+		bson.D{},
+		[]bson.D{
+			makeMetadataDoc("db", "a"),
+			makeMetadataDoc("db", "b"),
+			makeMetadataDoc("db", "a"),
+		},
+		nil,
+	)
+
+	warnings := bytes.Buffer{}
+	report, err := getReport(bytes.NewReader(dump), &warnings, ParseOptions{})
+	require.NoError(t, err, "should parse archive")
+
+	assert.Len(t, report.CollectionMetadata, 3, "should report all metadata")
+	assert.Equal(
+		t,
+		"collection metadata lists `db.a` more than once\n",
+		warnings.String(),
+		"should warn once about the duplicate",
+	)
+}
+
+func TestCollectionMetadataTerminator(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	cr := newCountingReader(bufio.NewReader(bytes.NewReader(dump)))
+	require.NoError(t, checkMagicBytes(cr, archive.MagicNumber), "should read magic bytes")
+
+	header := bson.D{}
+	require.NoError(t, readBSON(cr, &header), "should read header")
+
+	metadata, err := getCollectionMetadata(cr, newWarner(os.Stderr, false), ParseOptions{})
+	require.NoError(t, err, "should read collection metadata")
+	require.Len(t, metadata.docs, 4, "should read all collection metadata")
+
+	pos := cr.BytesRead()
+	assert.Equal(
+		t,
+		terminatorBytes,
+		dump[pos-int64(len(terminatorBytes)):pos],
+		"should consume the terminator",
+	)
+
+	nsHeader := archive.NamespaceHeader{}
+	require.NoError(t, readBSON(cr, &nsHeader), "should read namespace header next")
+	assert.NotEmpty(t, nsHeader.Database, "namespace header should have a database")
+}
+
+func TestErrors(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	_, err = getReport(bytes.NewReader([]byte("hello, world")), os.Stderr, ParseOptions{})
+	assert.ErrorIs(t, err, ErrBadMagic, "non-archive input")
+
+	_, err = getReport(bytes.NewReader(dump), os.Stderr, ParseOptions{MagicNumber: 0x12345678})
+	assert.ErrorIs(t, err, ErrBadMagic, "overridden magic number")
+
+	_, err = getReport(bytes.NewReader(dump[:2]), os.Stderr, ParseOptions{})
+	assert.ErrorIs(t, err, ErrTruncated, "input shorter than magic bytes")
+
+	_, err = getReport(bytes.NewReader(dump[:100]), os.Stderr, ParseOptions{})
+	assert.ErrorIs(t, err, ErrTruncated, "truncated header")
+	assert.ErrorIs(t, err, ErrBadHeader, "truncated header")
+
+	_, err = getReport(bytes.NewReader(dump[:1000]), os.Stderr, ParseOptions{})
+	assert.ErrorIs(t, err, ErrTruncated, "truncated metadata")
+	assert.ErrorIs(t, err, ErrMetadataParse, "truncated metadata")
+	assert.NotErrorIs(t, err, ErrBadHeader, "truncated metadata")
+}
+
+func TestExitCode(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	_, err = getReport(bytes.NewReader([]byte("hello, world")), os.Stderr, ParseOptions{})
+	assert.Equal(t, exitBadMagic, exitCode(err), "non-archive input")
+
+	_, err = getReport(bytes.NewReader(dump[:100]), os.Stderr, ParseOptions{})
+	assert.Equal(t, exitCorrupt, exitCode(err), "truncated header")
+
+	_, err = getReport(
+		bytes.NewReader(dump[:len(dump)-100]),
+		os.Stderr,
+		ParseOptions{CountDocuments: true},
+	)
+	assert.Equal(t, exitCorrupt, exitCode(err), "truncated body")
+
+	assert.Equal(t, exitFailure, exitCode(errors.New("oops")), "other failure")
+}
+
+func TestGetColumnWidth(t *testing.T) {
+	t.Setenv("COLUMNS", "")
+	assert.Equal(t, defaultColumnWidth, getColumnWidth(0), "should default without a terminal")
+
+	t.Setenv("COLUMNS", "120")
+	assert.Equal(t, 120, getColumnWidth(0), "should honor $COLUMNS")
+	assert.Equal(t, 50, getColumnWidth(50), "flag should override $COLUMNS")
+
+	t.Setenv("COLUMNS", "wide")
+	assert.Equal(t, defaultColumnWidth, getColumnWidth(0), "should ignore invalid $COLUMNS")
+
+	assert.NoError(t, validateWidth(0), "zero means unspecified")
+	assert.Error(t, validateWidth(5), "should reject tiny widths")
+}
+
+func TestParseMagicNumber(t *testing.T) {
+	for _, magicStr := range []string{"0x8199e26d", "2174345837"} {
+		magicNum, err := parseMagicNumber(magicStr)
+		require.NoError(t, err, "should parse %#q", magicStr)
+		assert.Equal(t, archive.MagicNumber, magicNum, "should parse %#q", magicStr)
+	}
+
+	for _, magicStr := range []string{"", "0", "0x100000000", "abc"} {
+		_, err := parseMagicNumber(magicStr)
+		assert.Error(t, err, "should reject %#q", magicStr)
+	}
+}
+
+func TestGetMagicNumber(t *testing.T) {
+	getMagic := func(explainOut io.Writer, args ...string) uint32 {
+		var magicNumber uint32
+
+		cmd := &cli.Command{
+			Name:  "test",
+			Flags: []cli.Flag{&cli.StringFlag{Name: "magic"}},
+			Action: func(_ context.Context, cmd *cli.Command) error {
+				var err error
+				magicNumber, err = getMagicNumber(cmd, explainOut)
+
+				return err
+			},
+		}
+
+		require.NoError(t, cmd.Run(context.Background(), append([]string{"test"}, args...)))
+
+		return magicNumber
+	}
+
+	explainOut := &bytes.Buffer{}
+	assert.Zero(t, getMagic(explainOut), "should default to 0")
+	assert.Empty(t, explainOut.String(), "should not note the default")
+
+	assert.EqualValues(t, 0x12345678, getMagic(nil, "--magic", "0x12345678"), "should parse --magic")
+
+	assert.EqualValues(t, 0x12345678, getMagic(explainOut, "--magic", "0x12345678"), "should parse --magic")
+	assert.Equal(
+		t,
+		"expecting magic number 0x12345678 rather than 0x8199e26d\n",
+		explainOut.String(),
+		"should note the override when explaining",
+	)
+}
+
+func makeMetadataDoc(db, coll string) bson.D {
+	return bson.D{
+		{Key: "db", Value: db},
+		{Key: "collection", Value: coll},
+	}
+}
+
+func makeMetadataDocWithOptions(db, coll string, options bson.D) bson.D {
+	return makeMetadataDocWithIndexes(db, coll, options, bson.A{})
+}
+
+func makeMetadataDocWithIndexes(db, coll string, options bson.D, indexes bson.A) bson.D {
+	return append(
+		makeMetadataDoc(db, coll),
+		bson.E{Key: "metadata", Value: bson.D{
+			{Key: "options", Value: options},
+			{Key: "indexes", Value: indexes},
+		}},
+	)
+}
+
+func TestReadBSONBuffered(t *testing.T) {
+	small, err := bson.Marshal(bson.D{{Key: "a", Value: 1}})
+	require.NoError(t, err, "should marshal")
+	large, err := bson.Marshal(bson.D{{Key: "b", Value: strings.Repeat("x", 100)}})
+	require.NoError(t, err, "should marshal")
+
+	input := bytes.NewReader(slices.Concat(large, small, large))
+	scratch := bsonBuffer{}
+
+	for _, expected := range []any{"x", 1, "x"} {
+		doc := bson.D{}
+		require.NoError(t, readBSONBuffered(input, &scratch, &doc), "should read document")
+		require.Len(t, doc, 1, "should decode document")
+
+		if s, ok := expected.(string); ok {
+			assert.Equal(t, strings.Repeat(s, 100), doc[0].Value, "should decode large document")
+		} else {
+			assert.EqualValues(t, expected, doc[0].Value, "should decode small document after large one")
+		}
+	}
+
+	doc := bson.D{}
+	err = readBSONBuffered(bytes.NewReader([]byte{2, 0, 0, 0}), &scratch, &doc)
+	assert.ErrorIs(t, err, ErrCorrupt, "should reject invalid length")
+
+	err = readBSONBuffered(bytes.NewReader(small[:len(small)-1]), &scratch, &doc)
+	assert.ErrorIs(t, err, ErrTruncated, "should report truncation")
+}
+
+func BenchmarkReadBSON(b *testing.B) {
+	mdDoc, err := bson.Marshal(makeMetadataDocWithIndexes("db", "coll", bson.D{}, bson.A{
+		bson.D{{Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}}, {Key: "name", Value: "_id_"}},
+	}))
+	require.NoError(b, err, "should marshal")
+
+	input := bytes.Repeat(mdDoc, 1000)
+
+	b.Run("single-shot", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for range b.N {
+			rdr := bytes.NewReader(input)
+			for rdr.Len() > 0 {
+				doc := bson.D{}
+				if err := readBSON(rdr, &doc); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("buffered", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for range b.N {
+			rdr := bytes.NewReader(input)
+			scratch := bsonBuffer{}
+			for rdr.Len() > 0 {
+				doc := bson.D{}
+				if err := readBSONBuffered(rdr, &scratch, &doc); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func TestMetadataErrorPolicy(t *testing.T) {
+	dump := makeArchive(
+		t,
+		bson.D{},
+		[]bson.D{
+			append(makeMetadataDoc("db", "bad"), bson.E{Key: "metadata", Value: "{not json"}),
+			append(makeMetadataDoc("other", "bad"), bson.E{Key: "metadata", Value: "{not json"}),
+			makeMetadataDoc("db", "good"),
+		},
+		nil,
+	)
+
+	for _, policy := range []string{"", metadataErrorsIgnore} {
+		warnings := bytes.Buffer{}
+		report, err := getReport(bytes.NewReader(dump), &warnings, ParseOptions{MetadataErrorPolicy: policy})
+		require.NoError(t, err, "policy %#q should not fail", policy)
+		assert.Contains(t, warnings.String(), "failed to parse collection metadata string for db.bad", "policy %#q should warn", policy)
+		assert.Empty(t, report.MetadataErrors, "policy %#q should not report failures", policy)
+		assert.Len(t, report.CollectionMetadata, 3, "policy %#q should keep all metadata", policy)
+	}
+
+	report, err := getReport(bytes.NewReader(dump), io.Discard, ParseOptions{MetadataErrorPolicy: metadataErrorsWarn, DBs: []string{"db"}})
+	require.NoError(t, err, "warn policy should not fail")
+	require.Len(t, report.MetadataErrors, 1, "warn policy should report failures in reported namespaces")
+	assert.Equal(t, "bad", report.MetadataErrors[0].Collection, "should report the failing namespace")
+	assert.NotEmpty(t, report.MetadataErrors[0].Error, "should report the parse error")
+
+	_, err = getReport(bytes.NewReader(dump), io.Discard, ParseOptions{MetadataErrorPolicy: metadataErrorsFail})
+	assert.ErrorIs(t, err, ErrMetadataParse, "fail policy should fail")
+	assert.ErrorContains(t, err, "db.bad", "fail policy should name the namespace")
+}
+
+func TestSkipBytes(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	expected, err := getReport(bytes.NewReader(dump), io.Discard, ParseOptions{})
+	require.NoError(t, err, "should parse dump")
+
+	withBOM := slices.Concat(utf8BOM, dump)
+
+	_, err = getReport(bytes.NewReader(withBOM), io.Discard, ParseOptions{})
+	assert.ErrorIs(t, err, ErrBadMagic, "BOM should fail magic check")
+	assert.ErrorContains(t, err, "not a mongodump archive (found a UTF-8 byte-order mark", "should identify BOM")
+
+	warnings := bytes.Buffer{}
+	report, err := getReport(bytes.NewReader(withBOM), &warnings, ParseOptions{SkipBytes: len(utf8BOM)})
+	require.NoError(t, err, "should skip BOM")
+	assert.Equal(t, expected, report, "skipping should not change the report")
+	assert.Contains(t, warnings.String(), "skipped 3 bytes before the archive", "should warn about skipping")
+
+	_, err = getReport(bytes.NewReader(utf8BOM), io.Discard, ParseOptions{SkipBytes: 10})
+	assert.ErrorIs(t, err, ErrTruncated, "skipping past end of input")
+}
+
+func TestCheckNotEmpty(t *testing.T) {
+	empty := makeArchive(t, bson.D{}, nil, nil)
+	report, err := getReport(bytes.NewReader(empty), io.Discard, ParseOptions{})
+	require.NoError(t, err, "should parse empty archive")
+	assert.ErrorIs(t, report.Summary.checkNotEmpty(), ErrEmpty, "no namespaces")
+
+	noDocs := makeArchive(t, bson.D{}, []bson.D{makeMetadataDoc("db", "a")}, []testBlock{
+		{db: "db", coll: "a", eof: true},
+	})
+	report, err = getReport(bytes.NewReader(noDocs), io.Discard, ParseOptions{})
+	require.NoError(t, err, "should parse archive")
+	assert.NoError(t, report.Summary.checkNotEmpty(), "documents unknown without reading the body")
+
+	for _, opts := range []ParseOptions{{CountDocuments: true}, {EstimateSizes: true}} {
+		report, err = getReport(bytes.NewReader(noDocs), io.Discard, opts)
+		require.NoError(t, err, "should parse archive")
+		assert.ErrorIs(t, report.Summary.checkNotEmpty(), ErrEmpty, "no documents with %+v", opts)
+	}
+
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+	report, err = getReport(bytes.NewReader(dump), io.Discard, ParseOptions{CountDocuments: true})
+	require.NoError(t, err, "should parse dump")
+	assert.NoError(t, report.Summary.checkNotEmpty(), "test dump has documents")
+}
+
+func TestGetRawHeader(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	header, err := getRawHeader(bytes.NewReader(dump), io.Discard, ParseOptions{})
+	require.NoError(t, err, "should read header")
+
+	headerLen := len(header)
+	assert.Equal(t, dump[4:4+headerLen], []byte(header), "should return the header’s exact bytes")
+
+	report, err := getReport(bytes.NewReader(dump), io.Discard, ParseOptions{HeaderOnly: true})
+	require.NoError(t, err, "should parse header")
+	decoded := bson.D{}
+	require.NoError(t, bson.Unmarshal(header, &decoded), "raw header should decode")
+	assert.Equal(t, report.Header, decoded, "raw header should match parsed header")
+
+	_, err = getRawHeader(bytes.NewReader(dump[:20]), io.Discard, ParseOptions{})
+	assert.ErrorIs(t, err, ErrBadHeader, "truncated header")
+	assert.ErrorIs(t, err, ErrTruncated, "truncated header")
+
+	_, err = getRawHeader(bytes.NewReader([]byte("hello, world")), io.Discard, ParseOptions{})
+	assert.ErrorIs(t, err, ErrBadMagic, "non-archive input")
+}
+
+func TestMaxCollections(t *testing.T) {
+	dump := makeArchive(
+		t,
+		bson.D{},
+		[]bson.D{makeMetadataDoc("db", "a"), makeMetadataDoc("db", "b"), makeMetadataDoc("db", "c")},
+		nil,
+	)
+
+	_, err := getReport(bytes.NewReader(dump), io.Discard, ParseOptions{MaxCollections: 3})
+	assert.NoError(t, err, "should accept exactly the maximum")
+
+	_, err = getReport(bytes.NewReader(dump), io.Discard, ParseOptions{MaxCollections: 2})
+	assert.ErrorContains(t, err, "more than 2 collection metadata documents", "should reject more than the maximum")
+
+	report, err := getReport(bytes.NewReader(dump), io.Discard, ParseOptions{MaxCollections: 2, MetadataLimit: 2})
+	require.NoError(t, err, "--head within the maximum should stop first")
+	assert.True(t, report.Truncated, "should stop at --head")
+}
+
+func TestHeaderTypes(t *testing.T) {
+	dump := makeArchive(
+		t,
+		bson.D{{Key: "concurrent_collections", Value: int64(4)}, {Key: "version", Value: "0.1"}},
+		nil,
+		nil,
+	)
+
+	for _, opts := range []ParseOptions{{HeaderTypes: true}, {HeaderTypes: true, HeaderOnly: true}} {
+		report, err := getReport(bytes.NewReader(dump), io.Discard, opts)
+		require.NoError(t, err, "should parse archive")
+
+		assert.Equal(
+			t,
+			[]HeaderFieldType{
+				{Field: "concurrent_collections", Type: "long", Value: int64(4)},
+				{Field: "version", Type: "string", Value: "0.1"},
+			},
+			report.HeaderTypes,
+			"should report each header field’s type (%+v)", opts,
+		)
+	}
+
+	report, err := getReport(bytes.NewReader(dump), io.Discard, ParseOptions{})
+	require.NoError(t, err, "should parse archive")
+	assert.Nil(t, report.HeaderTypes, "should omit types unless requested")
+}
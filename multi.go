@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// multiConflicts are the flags that make sense only for a single archive.
+var multiConflicts = []string{
+	"baseline",
+	"count-docs",
+	"exact",
+	"expect-namespaces",
+	"fail-on-empty",
+	"follow",
+	"log-format",
+	"roundtrip-check",
+	"split-by-db",
+	"stream",
+	"timing",
+	"url",
+}
+
+// ArchiveResult is one archive’s outcome in multi-archive mode: either its
+// report or the error that stopped parsing it.
+type ArchiveResult struct {
+	Source string  `bson:"source"`
+	Report *Report `bson:"report,omitempty"`
+	Error  string  `bson:"error,omitempty"`
+}
+
+func validateParallel(n int64) error {
+	if n < 1 {
+		return fmt.Errorf("parallelism must be positive, not %d", n)
+	}
+
+	return nil
+}
+
+// runMulti parses each of the command’s archive files, up to --parallel at
+// a time, and outputs a JSON array of their results, ordered by file name.
+// A failure to parse one archive doesn’t stop the others, but it does make
+// runMulti return an error once all results are output.
+func runMulti(ctx context.Context, cmd *cli.Command) error {
+	for _, name := range multiConflicts {
+		if cmd.IsSet(name) {
+			return fmt.Errorf("--%s cannot be used with multiple archives", name)
+		}
+	}
+
+	if format := cmd.String("format"); format != formatJSON {
+		return fmt.Errorf("cannot write %#q for multiple archives", format)
+	}
+
+	parseOpts, warnOut, err := getParseOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	// Concurrent progress bars would garble each other.
+	parseOpts.ProgressOut = nil
+
+	paths := slices.Sorted(slices.Values(cmd.Args().Slice()))
+	results, messages := parseArchives(ctx, paths, int(cmd.Int("parallel")), cmd.Bool("base64"), parseOpts)
+
+	// Each archive’s warnings were buffered so that they don’t interleave.
+	for i, path := range paths {
+		for _, line := range strings.SplitAfter(messages[i], "\n") {
+			if line != "" {
+				_, _ = fmt.Fprintf(warnOut, "%s: %s", path, line)
+			}
+		}
+	}
+
+	failed := 0
+
+	for i := range results {
+		if results[i].Report == nil {
+			failed++
+			continue
+		}
+
+		if !cmd.Bool("include-header") {
+			results[i].Report.Header = nil
+		}
+	}
+
+	opts := outputOptions{
+		pretty:     cmd.Bool("pretty"),
+		indent:     int(cmd.Int("indent")),
+		escapeHTML: cmd.Bool("escape-html"),
+		canonical:  cmd.Bool("canonical"),
+	}
+
+	err = writeArchiveResults(os.Stdout, results, opts)
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to parse %d of %d archives", failed, len(results))
+	}
+
+	return nil
+}
+
+// parseArchives parses the archive files at paths with up to parallel
+// concurrent workers. It returns each file’s result and warnings, in the
+// same order as paths.
+func parseArchives(
+	ctx context.Context,
+	paths []string,
+	parallel int,
+	isBase64 bool,
+	opts ParseOptions,
+) ([]ArchiveResult, []string) {
+	results := make([]ArchiveResult, len(paths))
+	messages := make([]string, len(paths))
+
+	sem := make(chan struct{}, parallel)
+	wg := sync.WaitGroup{}
+
+	for i, path := range paths {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			errOut := bytes.Buffer{}
+
+			fileOpts := opts
+			if fileOpts.ExplainOut != nil {
+				fileOpts.ExplainOut = &errOut
+			}
+
+			if fileOpts.WarningSummaryOut != nil {
+				fileOpts.WarningSummaryOut = &errOut
+			}
+
+			results[i] = parseArchiveFile(ctx, path, isBase64, &errOut, fileOpts)
+			messages[i] = errOut.String()
+		}()
+	}
+
+	wg.Wait()
+
+	return results, messages
+}
+
+// parseArchiveFile parses one archive file for parseArchives.
+func parseArchiveFile(
+	ctx context.Context,
+	path string,
+	isBase64 bool,
+	errOut io.Writer,
+	opts ParseOptions,
+) ArchiveResult {
+	result := ArchiveResult{Source: path}
+
+	if err := ctx.Err(); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		result.Error = errors.Wrapf(err, "failed to open %#q", path).Error()
+		return result
+	}
+	defer func() { _ = file.Close() }()
+
+	var input io.Reader = file
+	if isBase64 {
+		input = base64.NewDecoder(base64.StdEncoding, input)
+	}
+
+	report, err := getReport(newContextReader(ctx, input), errOut, opts)
+	if err != nil {
+		result.Error = errors.Wrap(err, "failed to parse archive").Error()
+		return result
+	}
+
+	err = report.setFileInfo(file)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Report = &report
+
+	return result
+}
+
+// writeArchiveResults writes the results as an Extended JSON array.
+func writeArchiveResults(out io.Writer, results []ArchiveResult, opts outputOptions) error {
+	buf := bytes.Buffer{}
+	buf.WriteByte('[')
+
+	for _, result := range results {
+		json, err := marshalExtJSON(result, opts)
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode result for %#q", result.Source)
+		}
+
+		writeArrayElement(&buf, json, opts)
+	}
+
+	closeArray(&buf, opts)
+
+	_, err := io.Copy(out, &buf)
+
+	return errors.Wrap(err, "failed to output results")
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseArchives(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	dir := t.TempDir()
+	paths := []string{
+		filepath.Join(dir, "a.archive"),
+		filepath.Join(dir, "b.archive"),
+		filepath.Join(dir, "c.archive"),
+		filepath.Join(dir, "missing.archive"),
+	}
+	require.NoError(t, os.WriteFile(paths[0], dump, 0o644), "should write archive")
+	require.NoError(t, os.WriteFile(paths[1], []byte("not an archive"), 0o644), "should write junk")
+	require.NoError(t, os.WriteFile(paths[2], dump, 0o644), "should write archive")
+
+	results, messages := parseArchives(context.Background(), paths, 2, false, ParseOptions{})
+	require.Len(t, results, len(paths), "should return a result per archive")
+
+	for i, result := range results {
+		assert.Equal(t, paths[i], result.Source, "should keep results in order")
+	}
+
+	require.NotNil(t, results[0].Report, "should parse first archive")
+	assert.Len(t, results[0].Report.CollectionMetadata, 4, "should report first archive")
+	assert.EqualValues(t, len(dump), *results[0].Report.FileSize, "should record file size")
+	assert.Contains(t, messages[0], "archive contains users", "should capture warnings")
+
+	assert.Nil(t, results[1].Report, "should not report junk")
+	assert.Contains(t, results[1].Error, "does not appear to be a mongodump archive", "should record junk’s error")
+
+	require.NotNil(t, results[2].Report, "should parse archive after a failure")
+
+	assert.Contains(t, results[3].Error, "failed to open", "should record missing file")
+
+	buf := bytes.Buffer{}
+	require.NoError(t, writeArchiveResults(&buf, results[1:2], outputOptions{}), "should write results")
+
+	decoded := bson.A{}
+	require.NoError(t, bson.UnmarshalExtJSON(buf.Bytes(), false, &decoded), "should write valid JSON")
+	assert.Equal(
+		t,
+		bson.A{bson.D{{Key: "source", Value: paths[1]}, {Key: "error", Value: results[1].Error}}},
+		decoded,
+		"should write error results",
+	)
+}
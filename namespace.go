@@ -0,0 +1,270 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Namespace is a typed view of a collection metadata document.
+type Namespace struct {
+	DB         string `bson:"db"`
+	Collection string `bson:"collection"`
+
+	// Type is “collection”, “view”, or “timeseries”.
+	Type string `bson:"type"`
+
+	Indexes []bson.D `bson:"indexes"`
+	Options bson.D   `bson:"options"`
+}
+
+// Namespaces returns the report’s collection metadata as Namespaces, in
+// the same order. CollectionMetadata retains the full documents.
+func (r Report) Namespaces() []Namespace {
+	namespaces := make([]Namespace, 0, len(r.CollectionMetadata))
+
+	for _, mdDoc := range r.CollectionMetadata {
+		ns := Namespace{
+			Indexes: getIndexes(mdDoc),
+			Options: getOptions(mdDoc),
+		}
+		ns.DB, ns.Collection = getNamespace(mdDoc)
+		ns.Type = getNamespaceType(mdDoc)
+
+		namespaces = append(namespaces, ns)
+	}
+
+	return namespaces
+}
+
+// getNamespaceType returns a collection metadata document’s type, which
+// the parsed metadata repeats. Old archives may lack both.
+func getNamespaceType(mdDoc bson.D) string {
+	if collType, err := bsonutil.FindStringValueByKey("type", &mdDoc); err == nil {
+		return collType
+	}
+
+	metadata, _ := getParsedMetadata(mdDoc)
+	collType, _ := bsonutil.FindStringValueByKey("type", &metadata)
+
+	return collType
+}
+
+// getNamespace returns the database & collection names from a collection
+// metadata document. Missing or non-string fields yield empty strings.
+func getNamespace(mdDoc bson.D) (string, string) {
+	db, _ := bsonutil.FindStringValueByKey("db", &mdDoc)
+	coll, _ := bsonutil.FindStringValueByKey("collection", &mdDoc)
+
+	return db, coll
+}
+
+// invalidDBChars are the characters that MongoDB forbids in database
+// names.
+const invalidDBChars = "/\\. \"$\x00"
+
+// validateNamespace checks that a collection metadata document names a
+// plausible namespace.
+func validateNamespace(mdDoc bson.D) error {
+	db, dbErr := bsonutil.FindStringValueByKey("db", &mdDoc)
+	coll, collErr := bsonutil.FindStringValueByKey("collection", &mdDoc)
+
+	switch {
+	case dbErr != nil:
+		return fmt.Errorf("collection metadata lacks a string “db”")
+	case collErr != nil:
+		return fmt.Errorf("collection metadata lacks a string “collection”")
+	case isOplogNamespace(db, coll):
+		return nil
+	case db == "":
+		return fmt.Errorf("collection metadata for %#q has an empty database name", coll)
+	case coll == "":
+		return fmt.Errorf("collection metadata for database %#q has an empty collection name", db)
+	case strings.ContainsAny(db, invalidDBChars):
+		return fmt.Errorf("database name %#q contains a forbidden character", db)
+	case strings.ContainsRune(coll, 0):
+		return fmt.Errorf("collection name %#q contains a null character", coll)
+	}
+
+	return nil
+}
+
+// isOplogNamespace mirrors mongo-tools’s Intent.IsOplog. mongodump writes
+// the oplog to archives as a database-less “oplog” collection, but we also
+// recognize the server’s own oplog namespaces.
+func isOplogNamespace(db, coll string) bool {
+	if db == "" && coll == "oplog" {
+		return true
+	}
+
+	return db == "local" && (coll == "oplog.rs" || coll == "oplog.$main")
+}
+
+// getParsedMetadata returns a collection metadata document’s parsed
+// “metadata” subdocument. It returns false if that field is missing or
+// (e.g., because parsing failed) isn’t a document.
+func getParsedMetadata(mdDoc bson.D) (bson.D, bool) {
+	metadata, err := bsonutil.FindSubdocumentByKey("metadata", &mdDoc)
+	if err != nil {
+		return nil, false
+	}
+
+	return metadata, true
+}
+
+// getIndexes returns the index specifications from a collection metadata
+// document. Malformed specifications are skipped.
+func getIndexes(mdDoc bson.D) []bson.D {
+	metadata, ok := getParsedMetadata(mdDoc)
+	if !ok {
+		return nil
+	}
+
+	indexesVal, err := bsonutil.FindValueByKey("indexes", &metadata)
+	if err != nil {
+		return nil
+	}
+
+	indexesArr, ok := indexesVal.(bson.A)
+	if !ok {
+		return nil
+	}
+
+	indexes := make([]bson.D, 0, len(indexesArr))
+	for _, index := range indexesArr {
+		if indexDoc, ok := index.(bson.D); ok {
+			indexes = append(indexes, indexDoc)
+		}
+	}
+
+	return indexes
+}
+
+// getOptions returns the collection options from a collection metadata
+// document, or nil if there are none.
+func getOptions(mdDoc bson.D) bson.D {
+	metadata, ok := getParsedMetadata(mdDoc)
+	if !ok {
+		return nil
+	}
+
+	return getSubdocument(metadata, "options")
+}
+
+// isCapped indicates whether a collection metadata document describes a
+// capped collection.
+func isCapped(mdDoc bson.D) bool {
+	options := getOptions(mdDoc)
+
+	capped, _ := bsonutil.FindValueByKey("capped", &options)
+
+	return capped == true
+}
+
+// getSubdocument returns doc’s subdocument at key, or nil if that field
+// is missing or isn’t a document.
+func getSubdocument(doc bson.D, key string) bson.D {
+	subdoc, err := bsonutil.FindSubdocumentByKey(key, &doc)
+	if err != nil {
+		return nil
+	}
+
+	return subdoc
+}
+
+// hasNamespace indicates whether any of the collection metadata documents
+// describes the given namespace.
+func hasNamespace(mdDocs []bson.D, db, coll string) bool {
+	for _, mdDoc := range mdDocs {
+		mdDB, mdColl := getNamespace(mdDoc)
+		if mdDB == db && mdColl == coll {
+			return true
+		}
+	}
+
+	return false
+}
+
+// namespaceFilter selects namespaces by exact database and collection
+// name. Names within each list are alternatives; the two lists must both
+// match. An empty list matches everything. Metadata must also satisfy all
+// of the predicates.
+type namespaceFilter struct {
+	dbs        []string
+	colls      []string
+	predicates []namespacePredicate
+}
+
+func (f namespaceFilter) matches(db, coll string) bool {
+	return (len(f.dbs) == 0 || slices.Contains(f.dbs, db)) &&
+		(len(f.colls) == 0 || slices.Contains(f.colls, coll))
+}
+
+func (f namespaceFilter) matchesMetadata(mdDoc bson.D) bool {
+	if !f.matches(getNamespace(mdDoc)) {
+		return false
+	}
+
+	for _, predicate := range f.predicates {
+		if !predicate(mdDoc) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// filterMetadata returns the collection metadata documents that match the
+// filter.
+func filterMetadata(mdDocs []bson.D, filter namespaceFilter) []bson.D {
+	return slices.DeleteFunc(slices.Clone(mdDocs), func(mdDoc bson.D) bool {
+		return !filter.matchesMetadata(mdDoc)
+	})
+}
+
+// namespaceMatcher returns a function that tells whether a
+// “db.collection” namespace matches the filter. Predicates need metadata,
+// so if there are any, only namespaces in filteredMDDocs match.
+func (f namespaceFilter) namespaceMatcher(filteredMDDocs []bson.D) func(string) bool {
+	if len(f.predicates) == 0 {
+		return func(ns string) bool {
+			db, coll, _ := strings.Cut(ns, ".")
+
+			return f.matches(db, coll)
+		}
+	}
+
+	included := map[string]bool{}
+	for _, mdDoc := range filteredMDDocs {
+		db, coll := getNamespace(mdDoc)
+		included[db+"."+coll] = true
+	}
+
+	return func(ns string) bool {
+		return included[ns]
+	}
+}
+
+// sortMetadata sorts collection metadata documents by database, then
+// collection.
+func sortMetadata(mdDocs []bson.D) {
+	slices.SortStableFunc(mdDocs, func(a, b bson.D) int {
+		aDB, aColl := getNamespace(a)
+		bDB, bColl := getNamespace(b)
+
+		return cmp.Or(strings.Compare(aDB, bDB), strings.Compare(aColl, bColl))
+	})
+}
+
+// filterNamespaceMap removes entries, keyed by namespace, that don’t
+// match.
+func filterNamespaceMap[V any](m map[string]V, matches func(string) bool) {
+	maps.DeleteFunc(m, func(ns string, _ V) bool {
+		return !matches(ns)
+	})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestValidateNamespace(t *testing.T) {
+	for _, mdDoc := range []bson.D{
+		makeMetadataDoc("db", "coll"),
+		makeMetadataDoc("db", "system.views"),
+		makeMetadataDoc("", "oplog"),
+	} {
+		assert.NoError(t, validateNamespace(mdDoc), "%v", mdDoc)
+	}
+
+	for _, mdDoc := range []bson.D{
+		makeMetadataDoc("", "coll"),
+		makeMetadataDoc("db", ""),
+		makeMetadataDoc("my.db", "coll"),
+		makeMetadataDoc("my db", "coll"),
+		makeMetadataDoc("db", "co\x00ll"),
+		{{Key: "collection", Value: "coll"}},
+		{{Key: "db", Value: int32(1)}, {Key: "collection", Value: "coll"}},
+	} {
+		assert.Error(t, validateNamespace(mdDoc), "%v", mdDoc)
+	}
+}
+
+func TestInvalidNamespaceWarning(t *testing.T) {
+	dump := makeArchive(
+		t,
+		bson.D{},
+		[]bson.D{makeMetadataDoc("db", "coll"), makeMetadataDoc("db", "")},
+		nil,
+	)
+
+	warnings := bytes.Buffer{}
+	report, err := getReport(bytes.NewReader(dump), &warnings, ParseOptions{})
+	require.NoError(t, err, "should parse archive")
+
+	assert.Len(t, report.CollectionMetadata, 2, "should still report invalid namespace")
+	assert.Equal(
+		t,
+		"collection metadata for database `db` has an empty collection name\n",
+		warnings.String(),
+		"should warn",
+	)
+}
+
+func TestReportNamespaces(t *testing.T) {
+	report := Report{}
+	require.NoError(t, bson.UnmarshalExtJSON([]byte(dumpExtJSON), false, &report), "should parse test’s ext JSON")
+
+	namespaces := report.Namespaces()
+	require.Len(t, namespaces, len(report.CollectionMetadata), "should return one Namespace per metadata document")
+
+	assert.Equal(
+		t,
+		Namespace{
+			DB:         "admin",
+			Collection: "system.users",
+			Type:       "collection",
+			Indexes: []bson.D{
+				{
+					{Key: "v", Value: int32(2)},
+					{Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}},
+					{Key: "name", Value: "_id_"},
+				},
+				{
+					{Key: "v", Value: int32(2)},
+					{Key: "key", Value: bson.D{{Key: "user", Value: int32(1)}, {Key: "db", Value: int32(1)}}},
+					{Key: "name", Value: "user_1_db_1"},
+					{Key: "unique", Value: true},
+				},
+			},
+		},
+		namespaces[1],
+		"should extract typed namespace",
+	)
+
+	for i, ns := range namespaces {
+		db, coll := getNamespace(report.CollectionMetadata[i])
+		assert.Equal(t, []string{db, coll}, []string{ns.DB, ns.Collection}, "should preserve order")
+	}
+
+	view := Report{CollectionMetadata: []bson.D{
+		append(
+			makeMetadataDocWithOptions("db", "v", bson.D{{Key: "viewOn", Value: "coll"}}),
+			bson.E{Key: "type", Value: "view"},
+		),
+		append(makeMetadataDoc("db", "old"), bson.E{Key: "metadata", Value: bson.D{{Key: "type", Value: "timeseries"}}}),
+	}}.Namespaces()
+
+	assert.Equal(t, "view", view[0].Type, "should read top-level type")
+	assert.Equal(t, bson.D{{Key: "viewOn", Value: "coll"}}, view[0].Options, "should extract options")
+	assert.Equal(t, "timeseries", view[1].Type, "should fall back to metadata type")
+}
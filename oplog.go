@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mongodb/mongo-tools/common/archive"
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OplogInfo describes the oplog that `mongodump --oplog` captures.
+type OplogInfo struct {
+	Present bool `bson:"present"`
+
+	// Entries is how many entries the oplog has, and FirstTS & LastTS are
+	// the earliest & latest entries’ “ts”. These are present only if
+	// getReport read the archive body.
+	Entries *int64               `bson:"entries,omitempty"`
+	FirstTS *primitive.Timestamp `bson:"firstTs,omitempty"`
+	LastTS  *primitive.Timestamp `bson:"lastTs,omitempty"`
+
+	// Since & EntriesSince are the --since timestamp and how many oplog
+	// entries occurred at or after it. EntriesSince is absent if the
+	// archive has no oplog.
+	Since        *primitive.Timestamp `bson:"since,omitempty"`
+	EntriesSince *int64               `bson:"entriesSince,omitempty"`
+}
+
+func getOplogInfo(mdDocs []bson.D) OplogInfo {
+	for _, mdDoc := range mdDocs {
+		if isOplogNamespace(getNamespace(mdDoc)) {
+			return OplogInfo{Present: true}
+		}
+	}
+
+	return OplogInfo{}
+}
+
+// parseOplogTimestamp parses a --since timestamp, which is either
+// “SECONDS[:ORDINAL]” (as for mongorestore --oplogLimit) or an RFC 3339
+// time.
+func parseOplogTimestamp(str string) (primitive.Timestamp, error) {
+	if t, err := time.Parse(time.RFC3339, str); err == nil {
+		if t.Unix() < 0 || t.Unix() > math.MaxUint32 {
+			return primitive.Timestamp{}, fmt.Errorf("time %#q is out of range for an oplog timestamp", str)
+		}
+
+		return primitive.Timestamp{T: uint32(t.Unix())}, nil
+	}
+
+	secondsStr, ordinalStr, hasOrdinal := strings.Cut(str, ":")
+
+	seconds, err := strconv.ParseUint(secondsStr, 10, 32)
+	if err != nil {
+		return primitive.Timestamp{}, fmt.Errorf("timestamp %#q must be SECONDS[:ORDINAL] or an RFC 3339 time", str)
+	}
+
+	var ordinal uint64
+	if hasOrdinal {
+		ordinal, err = strconv.ParseUint(ordinalStr, 10, 32)
+		if err != nil {
+			return primitive.Timestamp{}, fmt.Errorf("timestamp %#q has an invalid ordinal", str)
+		}
+	}
+
+	return primitive.Timestamp{T: uint32(seconds), I: uint32(ordinal)}, nil
+}
+
+func validateOplogTimestamp(str string) error {
+	_, err := parseOplogTimestamp(str)
+	return err
+}
+
+// oplogAnalyzer tallies the oplog’s entries: how many there are, the
+// range of their “ts”, and, if since is non-nil, how many are at or after
+// it.
+type oplogAnalyzer struct {
+	since *primitive.Timestamp
+
+	entries      int64
+	entriesSince int64
+	first, last  *primitive.Timestamp
+}
+
+func (oa *oplogAnalyzer) docLimit(header archive.NamespaceHeader) int {
+	if isOplogNamespace(header.Database, header.Collection) {
+		return math.MaxInt
+	}
+
+	return 0
+}
+
+func (oa *oplogAnalyzer) analyze(_ archive.NamespaceHeader, doc bson.D) {
+	value, _ := bsonutil.FindValueByKey("ts", &doc)
+	ts, ok := value.(primitive.Timestamp)
+
+	oa.tally(ts, ok)
+}
+
+// analyzeRaw reads just each entry’s “ts”, so readBody needn’t decode
+// the oplog.
+func (oa *oplogAnalyzer) analyzeRaw(_ archive.NamespaceHeader, raw bson.Raw) error {
+	t, i, ok := raw.Lookup("ts").TimestampOK()
+
+	oa.tally(primitive.Timestamp{T: t, I: i}, ok)
+
+	return nil
+}
+
+// tally counts an entry whose “ts” is ts, if hasTS.
+func (oa *oplogAnalyzer) tally(ts primitive.Timestamp, hasTS bool) {
+	oa.entries++
+
+	if !hasTS {
+		return
+	}
+
+	if oa.first == nil || ts.Before(*oa.first) {
+		oa.first = &ts
+	}
+	if oa.last == nil || ts.After(*oa.last) {
+		oa.last = &ts
+	}
+
+	if oa.since != nil && !ts.Before(*oa.since) {
+		oa.entriesSince++
+	}
+}
+
+// setOplogInfo adds the tallies to info, which describes the same
+// archive.
+func (oa *oplogAnalyzer) setOplogInfo(info *OplogInfo) {
+	if !info.Present {
+		return
+	}
+
+	info.Entries = &oa.entries
+	info.FirstTS = oa.first
+	info.LastTS = oa.last
+
+	if oa.since != nil {
+		info.EntriesSince = &oa.entriesSince
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+)
+
+// reportRegistry encodes reports. It differs from the default registry
+// only in that it writes maps’ keys in sorted order. Go randomizes map
+// iteration, so otherwise map-valued fields like Report.DocumentCounts
+// would come out in a different order on each run.
+var reportRegistry = newReportRegistry()
+
+func newReportRegistry() *bsoncodec.Registry {
+	registry := bson.NewRegistry()
+	registry.RegisterKindEncoder(reflect.Map, sortedMapEncoder{fallback: bsoncodec.NewMapCodec()})
+
+	return registry
+}
+
+// sortedMapEncoder encodes string-keyed maps as documents whose fields are
+// sorted by key. Other maps go to the fallback encoder.
+type sortedMapEncoder struct {
+	fallback bsoncodec.ValueEncoder
+}
+
+func (sme sortedMapEncoder) EncodeValue(ec bsoncodec.EncodeContext, vw bsonrw.ValueWriter, val reflect.Value) error {
+	if val.Kind() != reflect.Map || val.Type().Key().Kind() != reflect.String {
+		return sme.fallback.EncodeValue(ec, vw, val)
+	}
+
+	if val.IsNil() {
+		return vw.WriteNull()
+	}
+
+	keys := val.MapKeys()
+	slices.SortFunc(keys, func(a, b reflect.Value) int {
+		return strings.Compare(a.String(), b.String())
+	})
+
+	dw, err := vw.WriteDocument()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		elemVW, err := dw.WriteDocumentElement(key.String())
+		if err != nil {
+			return err
+		}
+
+		elem := val.MapIndex(key)
+		if elem.Kind() == reflect.Interface {
+			if elem.IsNil() {
+				err = elemVW.WriteNull()
+				if err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			elem = elem.Elem()
+		}
+
+		encoder, err := ec.LookupEncoder(elem.Type())
+		if err != nil {
+			return errors.Wrapf(err, "failed to find encoder for %#q", key.String())
+		}
+
+		err = encoder.EncodeValue(ec, elemVW, elem)
+		if err != nil {
+			return err
+		}
+	}
+
+	return dw.WriteDocumentEnd()
+}
+
+// marshalReportBSON is bson.Marshal with reportRegistry.
+func marshalReportBSON(value any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	vw, err := bsonrw.NewBSONValueWriter(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	encoder, err := newReportEncoder(vw)
+	if err != nil {
+		return nil, err
+	}
+
+	err = encoder.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// marshalReportExtJSON is bson.MarshalExtJSON with reportRegistry.
+func marshalReportExtJSON(value any, canonical, escapeHTML bool) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	vw, err := bsonrw.NewExtJSONValueWriter(buf, canonical, escapeHTML)
+	if err != nil {
+		return nil, err
+	}
+
+	encoder, err := newReportEncoder(vw)
+	if err != nil {
+		return nil, err
+	}
+
+	err = encoder.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unlike bson.MarshalExtJSON, the Encoder ends each document with a
+	// newline.
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func newReportEncoder(vw bsonrw.ValueWriter) (*bson.Encoder, error) {
+	encoder, err := bson.NewEncoder(vw)
+	if err != nil {
+		return nil, err
+	}
+
+	err = encoder.SetRegistry(reportRegistry)
+	if err != nil {
+		return nil, err
+	}
+
+	return encoder, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMapOrdering(t *testing.T) {
+	report := Report{
+		DocumentCounts: map[string]int64{},
+		Samples:        map[string][]bson.D{},
+	}
+
+	// Enough keys that random iteration would almost surely misorder them.
+	var namespaces []string
+	for i := range 50 {
+		ns := fmt.Sprintf("db.coll%02d", i)
+		namespaces = append(namespaces, ns)
+		report.DocumentCounts[ns] = int64(i)
+	}
+	report.Samples["db.b"] = []bson.D{{{Key: "_id", Value: int32(1)}}}
+	report.Samples["db.a"] = []bson.D{{{Key: "_id", Value: int32(2)}}}
+
+	getKeys := func(doc bson.D) []string {
+		var keys []string
+		for _, elem := range doc {
+			keys = append(keys, elem.Key)
+		}
+
+		return keys
+	}
+
+	doc, err := toDocument(report)
+	require.NoError(t, err)
+
+	counts, err := bsonutil.FindSubdocumentByKey("documentCounts", &doc)
+	require.NoError(t, err)
+	assert.Equal(t, namespaces, getKeys(counts), "BSON keys are sorted")
+
+	samples, err := bsonutil.FindSubdocumentByKey("samples", &doc)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db.a", "db.b"}, getKeys(samples), "BSON keys are sorted")
+
+	for _, pretty := range []bool{false, true} {
+		json, err := report.MarshalExtJSON(false, pretty)
+		require.NoError(t, err)
+
+		var decoded bson.D
+		require.NoError(t, bson.UnmarshalExtJSON(json, false, &decoded))
+
+		counts, err := bsonutil.FindSubdocumentByKey("documentCounts", &decoded)
+		require.NoError(t, err)
+		assert.True(t, slices.IsSorted(getKeys(counts)), "JSON keys are sorted (pretty: %t)", pretty)
+
+		again, err := report.MarshalExtJSON(false, pretty)
+		require.NoError(t, err)
+		assert.Equal(t, string(json), string(again), "JSON is stable (pretty: %t)", pretty)
+	}
+}
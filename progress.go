@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+const progressInterval = time.Second
+
+// progressReporter periodically writes parse progress to a terminal.
+// Its methods are no-ops on a nil receiver.
+type progressReporter struct {
+	out io.Writer
+
+	// total is how many bytes the parse will read, or 0 if unknown.
+	total int64
+
+	bytesRead  int64
+	namespaces int
+	start      time.Time
+	lastReport time.Time
+	reported   bool
+}
+
+func newProgressReporter(out io.Writer, total int64) *progressReporter {
+	now := time.Now()
+
+	return &progressReporter{
+		out:        out,
+		total:      total,
+		start:      now,
+		lastReport: now,
+	}
+}
+
+// setBytesRead records the number of bytes consumed so far.
+func (pr *progressReporter) setBytesRead(bytesRead int64) {
+	if pr == nil {
+		return
+	}
+
+	pr.bytesRead = bytesRead
+	pr.maybeReport()
+}
+
+// addNamespace records that the parser has seen another namespace.
+func (pr *progressReporter) addNamespace() {
+	if pr == nil {
+		return
+	}
+
+	pr.namespaces++
+	pr.maybeReport()
+}
+
+func (pr *progressReporter) maybeReport() {
+	if time.Since(pr.lastReport) < progressInterval {
+		return
+	}
+
+	pr.lastReport = time.Now()
+	pr.reported = true
+
+	// The escape sequence clears the rest of the line, which a longer
+	// earlier report may have filled.
+	_, _ = fmt.Fprintf(
+		pr.out,
+		"\r%s; %d namespace(s) seen\x1b[K",
+		formatProgress(pr.bytesRead, pr.total, pr.lastReport.Sub(pr.start)),
+		pr.namespaces,
+	)
+}
+
+// formatProgress describes how much of the input has been read. If the
+// total is known, this includes a percentage and an estimate, based on
+// the throughput so far, of the time remaining.
+func formatProgress(bytesRead, total int64, elapsed time.Duration) string {
+	if total <= 0 || bytesRead > total {
+		return "read " + formatBytes(bytesRead)
+	}
+
+	progress := fmt.Sprintf(
+		"read %s of %s (%d%%)",
+		formatBytes(bytesRead),
+		formatBytes(total),
+		bytesRead*100/total,
+	)
+
+	if bytesRead == 0 {
+		return progress
+	}
+
+	remaining := time.Duration(float64(elapsed) * float64(total-bytesRead) / float64(bytesRead))
+
+	return fmt.Sprintf("%s, about %s left", progress, remaining.Round(time.Second))
+}
+
+// finish ends the progress line, if one was written.
+func (pr *progressReporter) finish() {
+	if pr == nil || !pr.reported {
+		return
+	}
+
+	_, _ = fmt.Fprintln(pr.out)
+}
+
+// formatBytes renders a byte count with a binary unit prefix.
+func formatBytes(count int64) string {
+	const unit = 1024
+
+	if count < unit {
+		return fmt.Sprintf("%d B", count)
+	}
+
+	div, exp := int64(unit), 0
+	for n := count / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(count)/float64(div), "KMGTPE"[exp])
+}
+
+// formatTiming summarizes how long a parse took and its throughput.
+func formatTiming(bytesRead int64, elapsed time.Duration) string {
+	throughput := 0.0
+	if elapsed > 0 {
+		throughput = float64(bytesRead) / 1e6 / elapsed.Seconds()
+	}
+
+	// Small archives parse in well under a millisecond.
+	precision := time.Millisecond
+	if elapsed < time.Second {
+		precision = time.Microsecond
+	}
+
+	return fmt.Sprintf(
+		"parsed %s in %s (%.2f MB/s)",
+		formatBytes(bytesRead),
+		elapsed.Round(precision),
+		throughput,
+	)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "0 B", formatBytes(0))
+	assert.Equal(t, "1023 B", formatBytes(1023))
+	assert.Equal(t, "1.0 KiB", formatBytes(1024))
+	assert.Equal(t, "1.5 MiB", formatBytes(3*1024*1024/2))
+	assert.Equal(t, "2.0 GiB", formatBytes(2*1024*1024*1024))
+}
+
+func TestFormatTiming(t *testing.T) {
+	assert.Equal(t, "parsed 1.0 MiB in 500ms (2.10 MB/s)", formatTiming(1024*1024, 500*time.Millisecond))
+	assert.Equal(t, "parsed 1.0 KiB in 250µs (4.10 MB/s)", formatTiming(1024, 250*time.Microsecond))
+	assert.Equal(t, "parsed 1.0 GiB in 2.5s (429.50 MB/s)", formatTiming(1024*1024*1024, 2500*time.Millisecond+time.Microsecond))
+	assert.Equal(t, "parsed 0 B in 0s (0.00 MB/s)", formatTiming(0, 0))
+}
+
+func TestFormatProgress(t *testing.T) {
+	assert.Equal(t, "read 1.0 KiB", formatProgress(1024, 0, time.Second), "unknown total")
+	assert.Equal(t, "read 0 B of 4.0 KiB (0%)", formatProgress(0, 4096, 0), "nothing read yet")
+	assert.Equal(
+		t,
+		"read 1.0 KiB of 4.0 KiB (25%), about 30s left",
+		formatProgress(1024, 4096, 10*time.Second),
+		"should estimate time remaining",
+	)
+	assert.Equal(t, "read 8.0 KiB", formatProgress(8192, 4096, time.Second), "should ignore a wrong total")
+	assert.Equal(t, int64(0), ParseOptions{ProgressTotal: 4096}.progressTotal("gzip"), "should ignore total for compressed input")
+	assert.Equal(t, int64(4000), ParseOptions{ProgressTotal: 4096, SkipBytes: 96}.progressTotal(""), "should exclude skipped bytes")
+}
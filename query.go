@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// These are the query operators that --query supports. A field’s
+// condition is either a plain value, which the field must equal, or a
+// document of these operators, all of which the field must satisfy.
+const (
+	queryOpEq = "$eq"
+	queryOpIn = "$in"
+)
+
+// parseQuery parses a --query, which is an Extended JSON document like
+// `{"status": "A", "qty": {"$in": [1, 2]}}`.
+func parseQuery(queryStr string) (bson.D, error) {
+	query := bson.D{}
+
+	err := bson.UnmarshalExtJSON([]byte(queryStr), false, &query)
+	if err != nil {
+		return nil, errors.Wrapf(err, "query %#q is not an Extended JSON document", queryStr)
+	}
+
+	err = validateQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return query, nil
+}
+
+func validateQueryString(queryStr string) error {
+	_, err := parseQuery(queryStr)
+	return err
+}
+
+// validateQuery checks that a query uses only the supported subset of
+// MongoDB query syntax.
+func validateQuery(query bson.D) error {
+	for _, elem := range query {
+		if strings.HasPrefix(elem.Key, "$") {
+			return fmt.Errorf("query operator %#q is unsupported at the top level (expected field names)", elem.Key)
+		}
+
+		ops, ok := getQueryOperators(elem.Value)
+		if !ok {
+			continue
+		}
+
+		for _, op := range ops {
+			switch op.Key {
+			case queryOpEq:
+			case queryOpIn:
+				if _, ok := op.Value.(bson.A); !ok {
+					return fmt.Errorf("%#q’s %s needs an array, not %v", elem.Key, queryOpIn, op.Value)
+				}
+			default:
+				return fmt.Errorf("%#q’s query operator %#q is unsupported (expected %s or %s)", elem.Key, op.Key, queryOpEq, queryOpIn)
+			}
+		}
+	}
+
+	return nil
+}
+
+// getQueryOperators returns a field’s condition as operators, if it is
+// a document whose first key is an operator.
+func getQueryOperators(condition any) (bson.D, bool) {
+	ops, ok := condition.(bson.D)
+	if !ok || len(ops) == 0 || !strings.HasPrefix(ops[0].Key, "$") {
+		return nil, false
+	}
+
+	return ops, true
+}
+
+// matchesQuery reports whether doc satisfies a validated query. Only
+// top-level fields are compared, and arrays match only as a whole. As in
+// MongoDB, a missing field equals null.
+func matchesQuery(doc bson.D, query bson.D) bool {
+	for _, elem := range query {
+		value, err := bsonutil.FindValueByKey(elem.Key, &doc)
+		if err != nil {
+			value = nil
+		}
+
+		ops, ok := getQueryOperators(elem.Value)
+		if !ok {
+			ops = bson.D{{Key: queryOpEq, Value: elem.Value}}
+		}
+
+		for _, op := range ops {
+			candidates := []any{op.Value}
+			if op.Key == queryOpIn {
+				candidates, _ = op.Value.(bson.A)
+			}
+
+			if !containsQueryValue(candidates, value) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func containsQueryValue(candidates []any, value any) bool {
+	for _, candidate := range candidates {
+		if queryValuesEqual(candidate, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// queryValuesEqual compares numbers by value, so that, e.g., 1 equals
+// NumberLong(1), and other values by their BSON encoding.
+func queryValuesEqual(a, b any) bool {
+	if aNum, ok := bsonutil.Bson2Float64(a); ok {
+		bNum, ok := bsonutil.Bson2Float64(b)
+		return ok && aNum == bNum
+	}
+
+	equal, err := bsonutil.IsEqual(bson.D{{Key: "v", Value: a}}, bson.D{{Key: "v", Value: b}})
+
+	return err == nil && equal
+}
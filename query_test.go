@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMatchesQuery(t *testing.T) {
+	doc := bson.D{
+		{Key: "status", Value: "A"},
+		{Key: "qty", Value: int64(2)},
+		{Key: "tags", Value: bson.A{"x", "y"}},
+	}
+
+	for queryStr, expected := range map[string]bool{
+		`{}`:                                 true,
+		`{"status": "A"}`:                    true,
+		`{"status": "B"}`:                    false,
+		`{"qty": 2}`:                         true,
+		`{"qty": 2.0, "status": "A"}`:        true,
+		`{"qty": 2, "status": "B"}`:          false,
+		`{"qty": {"$in": [1, 2]}}`:           true,
+		`{"qty": {"$in": [1, 3]}}`:           false,
+		`{"status": {"$eq": "A"}}`:           true,
+		`{"tags": ["x", "y"]}`:               true,
+		`{"tags": "x"}`:                      false,
+		`{"missing": null}`:                  true,
+		`{"missing": {"$in": [null, 1]}}`:    true,
+		`{"missing": 1}`:                     false,
+		`{"qty": {"$eq": 2, "$in": [2, 3]}}`: true,
+		`{"qty": {"$eq": 2, "$in": [3, 4]}}`: false,
+	} {
+		query, err := parseQuery(queryStr)
+		require.NoError(t, err, "should parse %s", queryStr)
+		assert.Equal(t, expected, matchesQuery(doc, query), "%s", queryStr)
+	}
+
+	for _, queryStr := range []string{
+		`not json`,
+		`{"$or": [{"a": 1}]}`,
+		`{"qty": {"$gt": 1}}`,
+		`{"qty": {"$in": 1}}`,
+	} {
+		_, err := parseQuery(queryStr)
+		assert.Error(t, err, "should reject %s", queryStr)
+	}
+}
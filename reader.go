@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// countingReader tracks how many bytes have been consumed from a
+// bufio.Reader. Peeked bytes don’t count until they’re actually read.
+//
+// Parsing reads only via io.ReadFull, Peek, and Discard, all of which
+// retry short reads, so a slow stream like a FIFO reads correctly.
+// (A lone Read may return fewer bytes than requested.)
+type countingReader struct {
+	*bufio.Reader
+	count    int64
+	progress *progressReporter
+
+	// explainOut, if non-nil, receives a narration of parsing steps.
+	// explainColor dims the offsets in the narration.
+	explainOut   io.Writer
+	explainColor bool
+}
+
+func newCountingReader(rdr *bufio.Reader) *countingReader {
+	return &countingReader{Reader: rdr}
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.Reader.Read(p)
+	cr.count += int64(n)
+	cr.progress.setBytesRead(cr.count)
+
+	return n, err
+}
+
+func (cr *countingReader) Discard(n int) (int, error) {
+	discarded, err := cr.Reader.Discard(n)
+	cr.count += int64(discarded)
+	cr.progress.setBytesRead(cr.count)
+
+	return discarded, err
+}
+
+func (cr *countingReader) ReadByte() (byte, error) {
+	b, err := cr.Reader.ReadByte()
+	if err == nil {
+		cr.count++
+		cr.progress.setBytesRead(cr.count)
+	}
+
+	return b, err
+}
+
+// BytesRead returns the number of bytes consumed so far.
+func (cr *countingReader) BytesRead() int64 {
+	return cr.count
+}
+
+// explain narrates a parsing step that began at the given offset. It
+// does nothing unless explainOut is set.
+func (cr *countingReader) explain(offset int64, format string, args ...any) {
+	if cr.explainOut == nil {
+		return
+	}
+
+	position := fmt.Sprintf("[offset %d]", offset)
+	if cr.explainColor {
+		position = ansiDim + position + ansiReset
+	}
+
+	_, _ = fmt.Fprintf(cr.explainOut, "%s %s\n", position, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// credentialFields are the system.users fields that hold password hashes
+// & SCRAM keys.
+var credentialFields = []string{"credentials", "pwd"}
+
+// isUsersCollection indicates whether coll stores users. This matches
+// admin.system.users as well as the “$admin.system.users” collections
+// that `mongodump --dumpDbUsersAndRoles` creates.
+func isUsersCollection(coll string) bool {
+	return strings.TrimPrefix(coll, dbAuthPrefix) == "system.users"
+}
+
+// redactCredentials removes credential material from a user document.
+func redactCredentials(doc bson.D) bson.D {
+	for _, field := range credentialFields {
+		bsonutil.RemoveKey(field, &doc)
+	}
+
+	return doc
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/mongodb/mongo-tools/common/archive"
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// sampler collects the first documents of each namespace, or the first
+// that match opts.SampleQuery.
+type sampler struct {
+	opts    ParseOptions
+	samples map[string][]bson.D
+}
+
+func newSampler(opts ParseOptions) *sampler {
+	return &sampler{
+		opts:    opts,
+		samples: map[string][]bson.D{},
+	}
+}
+
+func (s *sampler) docLimit(archive.NamespaceHeader) int {
+	// Any document might match the query, so we must see them all.
+	if len(s.opts.SampleQuery) > 0 && s.opts.SampleSize > 0 {
+		return math.MaxInt
+	}
+
+	return s.opts.SampleSize
+}
+
+func (s *sampler) analyze(header archive.NamespaceHeader, doc bson.D) {
+	ns := header.Database + "." + header.Collection
+
+	if len(s.opts.SampleQuery) > 0 && len(s.samples[ns]) >= s.opts.SampleSize {
+		return
+	}
+
+	// Redaction alters the document, which other analyzers share.
+	doc = slices.Clone(doc)
+
+	// Redact before matching, lest whether a user is sampled reveal its
+	// credentials.
+	if isUsersCollection(header.Collection) && !s.opts.KeepCredentials {
+		doc = redactCredentials(doc)
+	}
+
+	if len(s.opts.SampleQuery) > 0 && !matchesQuery(doc, s.opts.SampleQuery) {
+		return
+	}
+
+	if len(s.opts.SampleFields) > 0 {
+		doc = projectDocument(doc, s.opts.SampleFields)
+	}
+
+	s.samples[ns] = append(s.samples[ns], doc)
+}
+
+// parseFieldList splits a comma-separated list of field names.
+func parseFieldList(fieldList string) []string {
+	var fields []string
+
+	for _, field := range strings.Split(fieldList, ",") {
+		field = strings.TrimSpace(field)
+		if field != "" {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// projectDocument returns a document with just the given fields from doc.
+// Dotted field names select nested fields, which keep their nesting in
+// the result. Missing fields are omitted.
+func projectDocument(doc bson.D, fields []string) bson.D {
+	projected := bson.D{}
+
+	for _, field := range fields {
+		path := strings.Split(field, ".")
+
+		value, found := findValueByPath(doc, path)
+		if found {
+			projected = setValueByPath(projected, path, value)
+		}
+	}
+
+	return projected
+}
+
+func findValueByPath(doc bson.D, path []string) (any, bool) {
+	value, err := bsonutil.FindValueByKey(path[0], &doc)
+	if err != nil {
+		return nil, false
+	}
+
+	if len(path) == 1 {
+		return value, true
+	}
+
+	subdoc, ok := value.(bson.D)
+	if !ok {
+		return nil, false
+	}
+
+	return findValueByPath(subdoc, path[1:])
+}
+
+func setValueByPath(doc bson.D, path []string, value any) bson.D {
+	if len(path) == 1 {
+		return append(doc, bson.E{Key: path[0], Value: value})
+	}
+
+	for i := range doc {
+		if doc[i].Key != path[0] {
+			continue
+		}
+
+		if subdoc, ok := doc[i].Value.(bson.D); ok {
+			doc[i].Value = setValueByPath(subdoc, path[1:], value)
+			return doc
+		}
+	}
+
+	return append(doc, bson.E{Key: path[0], Value: setValueByPath(bson.D{}, path[1:], value)})
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/mongodb/mongo-tools/common/archive"
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSample(t *testing.T) {
+	file, err := os.Open("test.dump")
+	require.NoError(t, err, "should open dump file")
+	defer func() { _ = file.Close() }()
+
+	report, err := getReport(file, os.Stderr, ParseOptions{SampleSize: 2, SampleFields: []string{"i"}})
+	require.NoError(t, err, "should parse dump")
+
+	assert.Equal(
+		t,
+		[]bson.D{
+			{{Key: "i", Value: int32(1000)}},
+			{{Key: "i", Value: int32(1001)}},
+		},
+		report.Samples["testDB.testColl"],
+		"should sample & project the first documents",
+	)
+	assert.Len(t, report.Samples, 4, "should sample every namespace")
+	assert.Nil(t, report.DocumentCounts, "should omit counts unless requested")
+}
+
+func TestProjectDocument(t *testing.T) {
+	doc := bson.D{
+		{Key: "a", Value: int32(1)},
+		{Key: "b", Value: bson.D{
+			{Key: "c", Value: "see"},
+			{Key: "d", Value: "dee"},
+		}},
+		{Key: "e", Value: "eee"},
+	}
+
+	assert.Equal(
+		t,
+		bson.D{
+			{Key: "a", Value: int32(1)},
+			{Key: "b", Value: bson.D{{Key: "c", Value: "see"}}},
+		},
+		projectDocument(doc, []string{"a", "b.c", "b.x", "missing", "e.f"}),
+		"should project top-level & nested fields",
+	)
+}
+
+func TestSampleRedactsCredentials(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		file, err := os.Open("test.dump")
+		require.NoError(t, err, "should open dump file")
+
+		report, err := getReport(file, os.Stderr, ParseOptions{SampleSize: 4, KeepCredentials: keep})
+		require.NoError(t, err, "should parse dump")
+		_ = file.Close()
+
+		users := report.Samples["admin.system.users"]
+		require.Len(t, users, 4, "should sample users")
+
+		for _, user := range users {
+			_, err := bsonutil.FindValueByKey("credentials", &user)
+			assert.Equal(t, keep, err == nil, "credentials present (keep=%t)", keep)
+		}
+
+		assert.NotEmpty(t, report.Samples["admin.system.roles"], "should sample non-user namespaces")
+	}
+}
+
+func TestSampleQuery(t *testing.T) {
+	file, err := os.Open("test.dump")
+	require.NoError(t, err, "should open dump file")
+	defer func() { _ = file.Close() }()
+
+	query, err := parseQuery(`{"i": {"$in": [1001, 1003, 1005]}}`)
+	require.NoError(t, err, "should parse query")
+
+	report, err := getReport(file, os.Stderr, ParseOptions{SampleSize: 2, SampleFields: []string{"i"}, SampleQuery: query})
+	require.NoError(t, err, "should parse dump")
+
+	assert.Equal(
+		t,
+		[]bson.D{
+			{{Key: "i", Value: int32(1001)}},
+			{{Key: "i", Value: int32(1003)}},
+		},
+		report.Samples["testDB.testColl"],
+		"should sample the first matching documents",
+	)
+}
+
+func TestSampleQueryRedactsFirst(t *testing.T) {
+	user := bson.D{
+		{Key: "_id", Value: "admin.alice"},
+		{Key: "pwd", Value: "hash"},
+	}
+	query, err := parseQuery(`{"pwd": "hash"}`)
+	require.NoError(t, err, "should parse query")
+
+	for _, keep := range []bool{false, true} {
+		s := newSampler(ParseOptions{SampleSize: 1, SampleQuery: query, KeepCredentials: keep})
+		s.analyze(archive.NamespaceHeader{Database: "admin", Collection: "system.users"}, user)
+
+		assert.Equal(t, keep, len(s.samples["admin.system.users"]) == 1, "credentials queryable (keep=%t)", keep)
+	}
+	assert.Len(t, user, 2, "should not modify the document")
+
+	for coll, isUsers := range map[string]bool{
+		"system.users":        true,
+		"$admin.system.users": true,
+		"mysystem.users":      false,
+		"app.system.users":    false,
+	} {
+		assert.Equal(t, isUsers, isUsersCollection(coll), "%#q stores users", coll)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/mongodb/mongo-tools/common/archive"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// schemaInferrer builds a rough $jsonSchema for each namespace from the
+// top-level fields of its first documents.
+type schemaInferrer struct {
+	limit   int
+	schemas map[string]*inferredSchema
+}
+
+type inferredSchema struct {
+	*fieldTally
+	fieldTypes map[string][]string
+}
+
+func newSchemaInferrer(limit int) *schemaInferrer {
+	return &schemaInferrer{
+		limit:   limit,
+		schemas: map[string]*inferredSchema{},
+	}
+}
+
+func (si *schemaInferrer) docLimit(archive.NamespaceHeader) int {
+	return si.limit
+}
+
+func (si *schemaInferrer) analyze(header archive.NamespaceHeader, doc bson.D) {
+	ns := header.Database + "." + header.Collection
+
+	schema, ok := si.schemas[ns]
+	if !ok {
+		schema = &inferredSchema{
+			fieldTally: newFieldTally(),
+			fieldTypes: map[string][]string{},
+		}
+		si.schemas[ns] = schema
+	}
+
+	schema.add(doc)
+
+	for _, elem := range doc {
+		typeName := bsonTypeName(elem.Value)
+		if !slices.Contains(schema.fieldTypes[elem.Key], typeName) {
+			schema.fieldTypes[elem.Key] = append(schema.fieldTypes[elem.Key], typeName)
+		}
+	}
+}
+
+// results returns the inferred schemas, keyed by namespace. A field is
+// deemed required if every examined document has it.
+func (si *schemaInferrer) results() map[string]bson.D {
+	results := map[string]bson.D{}
+
+	for ns, schema := range si.schemas {
+		required := bson.A{}
+		properties := bson.D{}
+
+		for _, field := range schema.fields {
+			if schema.fieldCounts[field] == schema.docCount {
+				required = append(required, field)
+			}
+
+			var bsonType any = schema.fieldTypes[field][0]
+			if len(schema.fieldTypes[field]) > 1 {
+				bsonType = schema.fieldTypes[field]
+			}
+
+			properties = append(properties, bson.E{Key: field, Value: bson.D{{Key: "bsonType", Value: bsonType}}})
+		}
+
+		result := bson.D{{Key: "bsonType", Value: "object"}}
+		if len(required) > 0 {
+			result = append(result, bson.E{Key: "required", Value: required})
+		}
+		results[ns] = append(result, bson.E{Key: "properties", Value: properties})
+	}
+
+	return results
+}
+
+// bsonTypeName returns the $jsonSchema bsonType alias for a value that
+// was decoded from BSON into a bson.D.
+func bsonTypeName(value any) string {
+	switch value.(type) {
+	case float64:
+		return "double"
+	case string:
+		return "string"
+	case bson.D:
+		return "object"
+	case bson.A:
+		return "array"
+	case primitive.Binary:
+		return "binData"
+	case primitive.Undefined:
+		return "undefined"
+	case primitive.ObjectID:
+		return "objectId"
+	case bool:
+		return "bool"
+	case primitive.DateTime:
+		return "date"
+	case nil, primitive.Null:
+		return "null"
+	case primitive.Regex:
+		return "regex"
+	case primitive.DBPointer:
+		return "dbPointer"
+	case primitive.JavaScript:
+		return "javascript"
+	case primitive.Symbol:
+		return "symbol"
+	case primitive.CodeWithScope:
+		return "javascriptWithScope"
+	case int32:
+		return "int"
+	case primitive.Timestamp:
+		return "timestamp"
+	case int64:
+		return "long"
+	case primitive.Decimal128:
+		return "decimal"
+	case primitive.MinKey:
+		return "minKey"
+	case primitive.MaxKey:
+		return "maxKey"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
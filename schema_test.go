@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mongodb/mongo-tools/common/archive"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSchemaInferrer(t *testing.T) {
+	header := archive.NamespaceHeader{Database: "testDB", Collection: "testColl"}
+
+	inferrer := newSchemaInferrer(10)
+	inferrer.analyze(header, bson.D{
+		{Key: "_id", Value: primitive.NewObjectID()},
+		{Key: "n", Value: int32(1)},
+		{Key: "tag", Value: "a"},
+	})
+	inferrer.analyze(header, bson.D{
+		{Key: "_id", Value: primitive.NewObjectID()},
+		{Key: "n", Value: int64(2)},
+	})
+
+	assert.Equal(
+		t,
+		map[string]bson.D{
+			"testDB.testColl": {
+				{Key: "bsonType", Value: "object"},
+				{Key: "required", Value: bson.A{"_id", "n"}},
+				{Key: "properties", Value: bson.D{
+					{Key: "_id", Value: bson.D{{Key: "bsonType", Value: "objectId"}}},
+					{Key: "n", Value: bson.D{{Key: "bsonType", Value: []string{"int", "long"}}}},
+					{Key: "tag", Value: bson.D{{Key: "bsonType", Value: "string"}}},
+				}},
+			},
+		},
+		inferrer.results(),
+		"should infer field types & required fields",
+	)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc64"
+	"slices"
+
+	"github.com/mongodb/mongo-tools/common/archive"
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v3"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// These describe the archive that buildSelfTestArchive synthesizes.
+const (
+	selfTestDB        = "selftest"
+	selfTestColl      = "docs"
+	selfTestView      = "docsView"
+	selfTestDocuments = 2
+)
+
+// runSelfTest synthesizes a small archive, parses it, and verifies the
+// resulting report. It needs no input, so it works as a smoke test for a
+// freshly built binary.
+func runSelfTest(ctx context.Context, cmd *cli.Command) error {
+	dump, err := buildSelfTestArchive()
+	if err != nil {
+		return errors.Wrap(err, "self-test failed to build archive")
+	}
+
+	report, err := getReport(
+		newContextReader(ctx, bytes.NewReader(dump)),
+		getWarningsOut(cmd),
+		ParseOptions{CountDocuments: true, VerifyCRC: true, CheckComplete: true},
+	)
+	if err != nil {
+		return errors.Wrap(err, "self-test failed to parse archive")
+	}
+
+	err = checkSelfTestReport(report, len(dump))
+	if err != nil {
+		return errors.Wrap(err, "self-test failed")
+	}
+
+	_, err = fmt.Fprintf(cmd.Writer, "self-test passed (%d-byte archive)\n", len(dump))
+
+	return err
+}
+
+// buildSelfTestArchive returns a minimal valid archive, built the way
+// mongodump writes one:
+//
+//  1. the magic number, little-endian
+//  2. the header document
+//  3. one metadata document per namespace, whose “metadata” is a string
+//     of Extended JSON
+//  4. a terminator (0xFFFFFFFF)
+//  5. for each namespace with documents, a namespace header, the
+//     documents, and a terminator, then a namespace header with EOF set
+//     and the CRC-64 (ECMA) of the documents
+func buildSelfTestArchive() ([]byte, error) {
+	buf := bytes.Buffer{}
+
+	err := binary.Write(&buf, binary.LittleEndian, archive.MagicNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	writeDoc := func(doc any) ([]byte, error) {
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to marshal %v", doc)
+		}
+		buf.Write(raw)
+
+		return raw, nil
+	}
+
+	header := bson.D{
+		{Key: "version", Value: archiveVersion},
+		{Key: "server_version", Value: "7.0.0"},
+		{Key: "tool_version", Value: "100.9.0"},
+		{Key: "concurrent_collections", Value: int32(1)},
+	}
+	if _, err := writeDoc(header); err != nil {
+		return nil, err
+	}
+
+	collMetadata := bson.D{
+		{Key: "options", Value: bson.D{}},
+		{Key: "indexes", Value: bson.A{
+			bson.D{
+				{Key: "v", Value: int32(2)},
+				{Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}},
+				{Key: "name", Value: "_id_"},
+			},
+		}},
+	}
+	viewMetadata := bson.D{
+		{Key: "options", Value: bson.D{
+			{Key: "viewOn", Value: selfTestColl},
+			{Key: "pipeline", Value: bson.A{}},
+		}},
+		{Key: "indexes", Value: bson.A{}},
+	}
+
+	for _, md := range []struct {
+		coll, collType string
+		metadata       bson.D
+	}{
+		{selfTestColl, "collection", collMetadata},
+		{selfTestView, "view", viewMetadata},
+	} {
+		json, err := bson.MarshalExtJSON(md.metadata, false, false)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal metadata")
+		}
+
+		_, err = writeDoc(bson.D{
+			{Key: "db", Value: selfTestDB},
+			{Key: "collection", Value: md.coll},
+			{Key: "metadata", Value: string(json)},
+			{Key: "size", Value: int32(0)},
+			{Key: "type", Value: md.collType},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	buf.Write(terminatorBytes)
+
+	crc := crc64.New(crc64.MakeTable(crc64.ECMA))
+
+	_, err = writeDoc(archive.NamespaceHeader{Database: selfTestDB, Collection: selfTestColl})
+	if err != nil {
+		return nil, err
+	}
+	for i := range selfTestDocuments {
+		raw, err := writeDoc(bson.D{{Key: "_id", Value: int32(i)}})
+		if err != nil {
+			return nil, err
+		}
+		_, _ = crc.Write(raw)
+	}
+	buf.Write(terminatorBytes)
+
+	_, err = writeDoc(archive.NamespaceHeader{
+		Database:   selfTestDB,
+		Collection: selfTestColl,
+		EOF:        true,
+		CRC:        int64(crc.Sum64()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(terminatorBytes)
+
+	return buf.Bytes(), nil
+}
+
+// checkSelfTestReport verifies that report describes the archive from
+// buildSelfTestArchive, which is size bytes long.
+func checkSelfTestReport(report Report, size int) error {
+	version, err := bsonutil.FindStringValueByKey("version", &report.Header)
+	if err != nil {
+		return errors.Wrap(err, "header lacks a version")
+	}
+	if version != archiveVersion {
+		return fmt.Errorf("expected header version %#q, not %#q", archiveVersion, version)
+	}
+
+	if report.ConcurrentCollections != 1 {
+		return fmt.Errorf("expected 1 concurrent collection, not %d", report.ConcurrentCollections)
+	}
+
+	var namespaces []string
+	for _, mdDoc := range report.CollectionMetadata {
+		db, coll := getNamespace(mdDoc)
+		namespaces = append(namespaces, db+"."+coll)
+	}
+	expectedNamespaces := []string{selfTestDB + "." + selfTestColl, selfTestDB + "." + selfTestView}
+	if !slices.Equal(namespaces, expectedNamespaces) {
+		return fmt.Errorf("expected namespaces %v, not %v", expectedNamespaces, namespaces)
+	}
+
+	expectedSummary := Summary{Databases: 1, Collections: 1, Views: 1, Indexes: 1}
+	summary := report.Summary
+	summary.Documents = nil
+	summary.Bytes = nil
+	if summary != expectedSummary {
+		return fmt.Errorf("expected summary %+v, not %+v", expectedSummary, summary)
+	}
+
+	count := report.DocumentCounts[selfTestDB+"."+selfTestColl]
+	if count != selfTestDocuments {
+		return fmt.Errorf("expected %d documents, not %d", selfTestDocuments, count)
+	}
+
+	if report.Complete == nil || !*report.Complete {
+		return fmt.Errorf("archive should be complete")
+	}
+
+	if report.BytesRead != int64(size) {
+		return fmt.Errorf("expected to read %d bytes, not %d", size, report.BytesRead)
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfTest(t *testing.T) {
+	dump, err := buildSelfTestArchive()
+	require.NoError(t, err)
+
+	report, err := getReport(
+		bytes.NewReader(dump),
+		io.Discard,
+		ParseOptions{CountDocuments: true, VerifyCRC: true, CheckComplete: true},
+	)
+	require.NoError(t, err)
+	assert.NoError(t, checkSelfTestReport(report, len(dump)))
+
+	assert.Error(t, checkSelfTestReport(report, len(dump)+1), "should notice wrong size")
+
+	report.CollectionMetadata = report.CollectionMetadata[:1]
+	assert.Error(t, checkSelfTestReport(report, len(dump)), "should notice missing namespace")
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math"
+
+	"github.com/mongodb/mongo-tools/common/archive"
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// shardKeyCollector gathers shard key patterns from the archive’s
+// config.collections documents, which a dump of a config server (or of a
+// cluster’s config database) includes.
+type shardKeyCollector struct {
+	enabled bool
+	keys    map[string]bson.D
+}
+
+func newShardKeyCollector(enabled bool) *shardKeyCollector {
+	return &shardKeyCollector{
+		enabled: enabled,
+		keys:    map[string]bson.D{},
+	}
+}
+
+func (skc *shardKeyCollector) docLimit(header archive.NamespaceHeader) int {
+	if skc.enabled && header.Database == "config" && header.Collection == "collections" {
+		return math.MaxInt
+	}
+
+	return 0
+}
+
+func (skc *shardKeyCollector) analyze(_ archive.NamespaceHeader, doc bson.D) {
+	ns, err := bsonutil.FindStringValueByKey("_id", &doc)
+	if err != nil {
+		return
+	}
+
+	// Older servers leave entries for dropped collections.
+	if dropped, _ := bsonutil.FindValueByKey("dropped", &doc); dropped == true {
+		return
+	}
+
+	if key := getSubdocument(doc, "key"); key != nil {
+		skc.keys[ns] = key
+	}
+}
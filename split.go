@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// oplogSplitName is the file name stem for the oplog, which belongs to no
+// database. Database names can’t contain “.”, so no database’s file can
+// collide with it.
+const oplogSplitName = "oplog.rs"
+
+var splitExtensions = map[string]string{
+	formatJSON:    ".json",
+	formatCSV:     ".csv",
+	formatTable:   ".txt",
+	formatBSON:    ".bson",
+	formatIndexes: ".json",
+	formatNDJSON:  ".ndjson",
+}
+
+// getReportDBs returns the databases in the report’s collection metadata,
+// in order of first appearance.
+func getReportDBs(report Report) []string {
+	var dbs []string
+
+	for _, mdDoc := range report.CollectionMetadata {
+		db, _ := getNamespace(mdDoc)
+		if !slices.Contains(dbs, db) {
+			dbs = append(dbs, db)
+		}
+	}
+
+	return dbs
+}
+
+// getDBReport derives a report on only the given database’s namespaces.
+// Archive-wide fields, like the header, are unchanged.
+func getDBReport(report Report, db string) Report {
+	filter := namespaceFilter{dbs: []string{db}}
+	matchesNS := filter.namespaceMatcher(nil)
+
+	dbReport := report
+	dbReport.CollectionMetadata = filterMetadata(report.CollectionMetadata, filter)
+
+	dbReport.MetadataErrors = slices.DeleteFunc(
+		slices.Clone(report.MetadataErrors),
+		func(mdErr MetadataError) bool { return mdErr.DB != db },
+	)
+	dbReport.CollectionDetails = slices.DeleteFunc(
+		slices.Clone(report.CollectionDetails),
+		func(details CollectionDetails) bool { return details.DB != db },
+	)
+	dbReport.Indexes = slices.DeleteFunc(
+		slices.Clone(report.Indexes),
+		func(indexes NamespaceIndexes) bool { return indexes.DB != db },
+	)
+	dbReport.CappedOverflows = slices.DeleteFunc(
+		slices.Clone(report.CappedOverflows),
+		func(overflow CappedOverflow) bool { return overflow.DB != db },
+	)
+	dbReport.GridFSBuckets = slices.DeleteFunc(
+		slices.Clone(report.GridFSBuckets),
+		func(bucket GridFSBucket) bool { return bucket.DB != db },
+	)
+
+	dbReport.AuthDatabases = slices.DeleteFunc(
+		slices.Clone(report.AuthDatabases),
+		func(auth DatabaseAuth) bool { return auth.DB != db },
+	)
+
+	dbReport.DocumentCounts = cloneNamespaceMap(report.DocumentCounts, matchesNS)
+	dbReport.EstimatedSizes = cloneNamespaceMap(report.EstimatedSizes, matchesNS)
+	dbReport.Blocks = cloneNamespaceMap(report.Blocks, matchesNS)
+	dbReport.BlockCompression = cloneNamespaceMap(report.BlockCompression, matchesNS)
+	dbReport.Samples = cloneNamespaceMap(report.Samples, matchesNS)
+	dbReport.Schemas = cloneNamespaceMap(report.Schemas, matchesNS)
+	dbReport.FieldStats = cloneNamespaceMap(report.FieldStats, matchesNS)
+	dbReport.IDTypes = cloneNamespaceMap(report.IDTypes, matchesNS)
+	dbReport.docBytes = cloneNamespaceMap(report.docBytes, matchesNS)
+
+	dbReport.Summary = getSummary(dbReport.CollectionMetadata, dbReport.DocumentCounts, dbReport.docBytes)
+
+	return dbReport
+}
+
+// cloneNamespaceMap returns a copy of m, which is keyed by namespace, with
+// only the namespaces that match. A nil map stays nil.
+func cloneNamespaceMap[V any](m map[string]V, matches func(string) bool) map[string]V {
+	clone := maps.Clone(m)
+	filterNamespaceMap(clone, matches)
+
+	return clone
+}
+
+// writeSplitReports writes one report file per database into dir. Each
+// file is self-contained, so each repeats the archive-wide fields.
+func writeSplitReports(dir string, report Report, opts outputOptions) error {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %#q", dir)
+	}
+
+	ext := splitExtensions[opts.format]
+	if ext == "" {
+		ext = splitExtensions[formatJSON]
+	}
+
+	// The names come from the archive, so distinct databases might still
+	// map to one file, e.g., on a case-insensitive filesystem.
+	dbsByName := map[string]string{}
+
+	for _, db := range getReportDBs(report) {
+		path, err := getSplitPath(dir, db, ext)
+		if err != nil {
+			return err
+		}
+
+		name := strings.ToLower(filepath.Base(path))
+		if other, ok := dbsByName[name]; ok {
+			return fmt.Errorf("databases %#q and %#q would both be written to %#q", other, db, path)
+		}
+		dbsByName[name] = db
+
+		err = writeReportFile(path, getDBReport(report, db), opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getSplitPath returns the path in dir of the given database’s report
+// file. Since the database name comes from the archive, it fails rather
+// than return a path outside dir.
+func getSplitPath(dir, db, ext string) (string, error) {
+	name := db
+	if name == "" {
+		name = oplogSplitName
+	} else if strings.ContainsAny(name, `/\`+"\x00") || strings.Contains(name, "..") {
+		return "", fmt.Errorf("cannot write a report file for database %#q, whose name isn’t a safe file name", db)
+	}
+
+	path := filepath.Join(dir, name+ext)
+
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || strings.HasPrefix(rel, "..") || filepath.Dir(rel) != "." {
+		return "", fmt.Errorf("cannot write a report file for database %#q outside %#q", db, dir)
+	}
+
+	return path, nil
+}
+
+func writeReportFile(path string, report Report, opts outputOptions) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %#q", path)
+	}
+
+	err = writeReport(file, report, opts)
+	if err != nil {
+		_ = file.Close()
+		return errors.Wrapf(err, "failed to write %#q", path)
+	}
+
+	return errors.Wrapf(file.Close(), "failed to write %#q", path)
+}
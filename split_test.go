@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSplitByDB(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	report, err := getReport(bytes.NewReader(dump), io.Discard, ParseOptions{CountDocuments: true})
+	require.NoError(t, err, "should parse dump")
+
+	assert.Equal(t, []string{"testDB", "admin"}, getReportDBs(report), "should list databases in order")
+
+	adminReport := getDBReport(report, "admin")
+	assert.Len(t, adminReport.CollectionMetadata, 3, "should keep only admin metadata")
+	assert.Equal(
+		t,
+		map[string]int64{"admin.system.users": 4, "admin.system.roles": 4, "admin.system.version": 2},
+		adminReport.DocumentCounts,
+		"should keep only admin counts",
+	)
+	assert.Equal(t, report.Header, adminReport.Header, "should keep header")
+	assert.Equal(t, 1, adminReport.Summary.Databases, "should summarize one database")
+	assert.EqualValues(t, 10, *adminReport.Summary.Documents, "should summarize admin documents")
+	assert.Len(t, report.CollectionMetadata, 4, "should not modify original report")
+
+	dir := filepath.Join(t.TempDir(), "split")
+	require.NoError(t, writeSplitReports(dir, report, outputOptions{format: formatJSON}), "should write reports")
+
+	for db, collections := range map[string]int{"testDB": 1, "admin": 3} {
+		json, err := os.ReadFile(filepath.Join(dir, db+".json"))
+		require.NoError(t, err, "should write %#q report", db)
+
+		dbReport := Report{}
+		require.NoError(t, bson.UnmarshalExtJSON(json, false, &dbReport), "%#q report should be valid", db)
+		assert.Len(t, dbReport.CollectionMetadata, collections, "%#q report should have its collections", db)
+		assert.NotEmpty(t, dbReport.Header, "%#q report should include header", db)
+	}
+}
+
+func TestSplitByDBUnsafeNames(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, db := range []string{"../escape", "a/b", `a\b`, "..", "a\x00b"} {
+		_, err := getSplitPath(dir, db, ".json")
+		assert.Error(t, err, "should reject %#q", db)
+	}
+
+	path, err := getSplitPath(dir, "", ".json")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "oplog.rs.json"), path, "should name the oplog’s file")
+
+	path, err = getSplitPath(dir, "_oplog", ".json")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "_oplog.json"), path, "should keep a database’s own name")
+
+	report := Report{CollectionMetadata: []bson.D{
+		makeMetadataDoc("../escape", "coll"),
+	}}
+	splitDir := filepath.Join(dir, "split")
+	assert.Error(t, writeSplitReports(splitDir, report, outputOptions{format: formatJSON}), "should reject traversal")
+	_, err = os.Stat(filepath.Join(dir, "escape.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist, "should not write outside the directory")
+
+	report = Report{CollectionMetadata: []bson.D{
+		makeMetadataDoc("Shop", "coll"),
+		makeMetadataDoc("shop", "coll"),
+	}}
+	assert.ErrorContains(
+		t,
+		writeSplitReports(splitDir, report, outputOptions{format: formatJSON}),
+		"would both be written",
+		"should reject colliding file names",
+	)
+}
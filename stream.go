@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// streamConflicts are the flags that need the whole report at once, so
+// --stream can’t honor them.
+var streamConflicts = []string{
+	"baseline",
+	"count-docs",
+	"fail-on-empty",
+	"include-header",
+	"log-format",
+	"only-header",
+	"pretty",
+	"pretty-metadata",
+	"roundtrip-check",
+	"split-by-db",
+}
+
+// streamReport is like getReport followed by writeJSON, except that it
+// writes each collection metadata document as soon as it reads it rather
+// than retaining them all. Its memory use thus doesn’t grow with the
+// archive. The output has only the report version, Extended JSON mode,
+// header, collection metadata, bytes read, and (per
+// opts.MetadataErrorPolicy) metadata errors.
+//
+// Options that need the whole report, like reading the body or sorting
+// namespaces, are unsupported. If an error occurs partway, the output is
+// incomplete JSON.
+func streamReport(input io.Reader, out, errOut io.Writer, opts ParseOptions, outOpts outputOptions) error {
+	switch {
+	case opts.readsBody():
+		return fmt.Errorf("cannot stream a report that reads the archive body")
+	case opts.SortNamespaces:
+		return fmt.Errorf("cannot stream a report with sorted namespaces")
+	case opts.HashAlgorithm != "":
+		return fmt.Errorf("cannot stream a report with an input digest")
+	case opts.HeaderOnly:
+		return fmt.Errorf("cannot stream a report of only the header")
+	}
+
+	filter, err := opts.namespaceFilter()
+	if err != nil {
+		return err
+	}
+
+	input, compression, err := openArchive(input, errOut, opts)
+	if err != nil {
+		return err
+	}
+
+	cr := newCountingReader(bufio.NewReader(input))
+	cr.explainOut, cr.explainColor = opts.ExplainOut, opts.ExplainColor
+
+	if opts.ProgressOut != nil {
+		cr.progress = newProgressReporter(opts.ProgressOut, opts.progressTotal(compression))
+		defer cr.progress.finish()
+	}
+
+	header, err := readHeader(cr, opts)
+	if err != nil {
+		return err
+	}
+
+	warner := newWarner(errOut, opts.Strict)
+	defer warner.writeSummary(opts.WarningSummaryOut)
+
+	err = checkArchiveVersion(header, warner)
+	if err != nil {
+		return err
+	}
+
+	bufOut := bufio.NewWriter(out)
+
+	extJSONMode := Report{}.withExtJSONMode(outOpts).ExtJSONMode
+	_, _ = fmt.Fprintf(bufOut, `{"reportVersion":%d,"extJsonMode":%q,"header":`, reportVersion, extJSONMode)
+
+	err = writeStreamValue(bufOut, header, outOpts)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode archive header")
+	}
+
+	_, _ = bufOut.WriteString(`,"collectionMetadata":[`)
+
+	written := 0
+
+	// These are the written namespaces whose metadata failed to parse.
+	unparsed := map[string]bool{}
+
+	metadata, err := readCollectionMetadata(cr, warner, opts, func(mdDoc bson.D) error {
+		if !filter.matchesMetadata(mdDoc) {
+			return nil
+		}
+
+		if written > 0 {
+			_ = bufOut.WriteByte(',')
+		}
+		written++
+
+		db, coll := getNamespace(mdDoc)
+
+		if _, ok := getParsedMetadata(mdDoc); !ok {
+			unparsed[db+"."+coll] = true
+		}
+
+		if opts.CompactMetadata {
+			mdDoc = compactMetadata(mdDoc)
+		}
+
+		return errors.Wrapf(writeStreamValue(bufOut, mdDoc, outOpts), "failed to encode %s.%s metadata", db, coll)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to read collection metadata")
+	}
+
+	_, _ = fmt.Fprintf(bufOut, `],"bytesRead":%d`, cr.BytesRead())
+
+	if opts.MetadataErrorPolicy == metadataErrorsWarn {
+		mdErrors := slices.DeleteFunc(metadata.parseErrors, func(mdErr MetadataError) bool {
+			return !unparsed[mdErr.DB+"."+mdErr.Collection]
+		})
+
+		if len(mdErrors) > 0 {
+			_, _ = bufOut.WriteString(`,"metadataErrors":[`)
+
+			for i, mdErr := range mdErrors {
+				if i > 0 {
+					_ = bufOut.WriteByte(',')
+				}
+
+				err := writeStreamValue(bufOut, mdErr, outOpts)
+				if err != nil {
+					return errors.Wrap(err, "failed to encode metadata error")
+				}
+			}
+
+			_ = bufOut.WriteByte(']')
+		}
+	}
+
+	_ = bufOut.WriteByte('}')
+
+	return errors.Wrap(bufOut.Flush(), "failed to output report")
+}
+
+// writeStreamValue writes a compact Extended JSON encoding of value.
+// (bufio.Writer defers write errors to Flush.)
+func writeStreamValue(out *bufio.Writer, value any, opts outputOptions) error {
+	opts.pretty = false
+
+	json, err := marshalExtJSON(value, opts)
+	if err != nil {
+		return err
+	}
+
+	_, _ = out.Write(json)
+
+	return nil
+}
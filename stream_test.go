@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestStreamReport(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	for _, opts := range []ParseOptions{{}, {DBs: []string{"admin"}}} {
+		expected, err := getReport(bytes.NewReader(dump), io.Discard, opts)
+		require.NoError(t, err, "should parse dump")
+
+		buf := bytes.Buffer{}
+		require.NoError(t, streamReport(bytes.NewReader(dump), &buf, io.Discard, opts, outputOptions{}), "should stream report")
+
+		streamed := Report{}
+		require.NoError(t, bson.UnmarshalExtJSON(buf.Bytes(), false, &streamed), "output should be valid Extended JSON")
+
+		assert.Equal(t, expected.ReportVersion, streamed.ReportVersion, "should stream report version (%+v)", opts)
+		assert.Equal(t, expected.Header, streamed.Header, "should stream header (%+v)", opts)
+		assert.Equal(t, expected.CollectionMetadata, streamed.CollectionMetadata, "should stream metadata (%+v)", opts)
+		assert.Equal(t, expected.BytesRead, streamed.BytesRead, "should stream bytes read (%+v)", opts)
+	}
+
+	for _, opts := range []ParseOptions{{CountDocuments: true}, {SortNamespaces: true}, {HeaderOnly: true}} {
+		err := streamReport(bytes.NewReader(dump), io.Discard, io.Discard, opts, outputOptions{})
+		assert.Error(t, err, "should reject %+v", opts)
+	}
+}
+
+func TestStreamReportMetadataErrors(t *testing.T) {
+	dump := makeArchive(
+		t,
+		bson.D{},
+		[]bson.D{
+			append(makeMetadataDoc("db", "bad"), bson.E{Key: "metadata", Value: "{not json"}),
+			append(makeMetadataDoc("other", "bad"), bson.E{Key: "metadata", Value: "{not json"}),
+			makeMetadataDoc("db", "good"),
+		},
+		nil,
+	)
+
+	buf := bytes.Buffer{}
+	err := streamReport(
+		bytes.NewReader(dump),
+		&buf,
+		io.Discard,
+		ParseOptions{MetadataErrorPolicy: metadataErrorsWarn, DBs: []string{"db"}},
+		outputOptions{},
+	)
+	require.NoError(t, err, "should stream report")
+
+	streamed := Report{}
+	require.NoError(t, bson.UnmarshalExtJSON(buf.Bytes(), false, &streamed), "output should be valid Extended JSON")
+	assert.Len(t, streamed.CollectionMetadata, 2, "should filter metadata")
+	require.Len(t, streamed.MetadataErrors, 1, "should report errors in streamed namespaces")
+	assert.Equal(t, "db", streamed.MetadataErrors[0].DB, "should report errors in streamed namespaces")
+}
+
+func TestStreamReportExtJSONMode(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	for mode, canonical := range map[string]bool{extJSONRelaxed: false, extJSONCanonical: true} {
+		buf := bytes.Buffer{}
+		outOpts := outputOptions{canonical: canonical}
+		require.NoError(t, streamReport(bytes.NewReader(dump), &buf, io.Discard, ParseOptions{}, outOpts), "should stream report")
+
+		streamed := Report{}
+		require.NoError(t, bson.UnmarshalExtJSON(buf.Bytes(), false, &streamed), "output should be valid Extended JSON")
+		assert.Equal(t, mode, streamed.ExtJSONMode, "should say which Extended JSON mode it uses")
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Summary totals the report’s namespaces. Documents is present only when
+// the archive body was read to count documents, and Bytes only when it was
+// read to count documents or estimate sizes.
+type Summary struct {
+	Databases   int    `bson:"databases"`
+	Collections int    `bson:"collections"`
+	Views       int    `bson:"views"`
+	Indexes     int    `bson:"indexes"`
+	Documents   *int64 `bson:"documents,omitempty"`
+	Bytes       *int64 `bson:"bytes,omitempty"`
+
+	// TimeSeries counts the time-series collections whose buckets
+	// collections the archive also contains. Each such pair is also
+	// counted among Collections or Views.
+	TimeSeries int `bson:"timeSeries,omitempty"`
+}
+
+// getSummary totals the given collection metadata. If docCounts &
+// docBytes are non-nil, it also totals those for the same namespaces.
+func getSummary(mdDocs []bson.D, docCounts, docBytes map[string]int64) Summary {
+	summary := Summary{}
+	dbs := map[string]struct{}{}
+
+	var totalDocs, totalBytes int64
+
+	for _, mdDoc := range mdDocs {
+		db, coll := getNamespace(mdDoc)
+		dbs[db] = struct{}{}
+
+		if collType, _ := bsonutil.FindStringValueByKey("type", &mdDoc); collType == "view" {
+			summary.Views++
+		} else {
+			summary.Collections++
+		}
+
+		summary.Indexes += len(getIndexes(mdDoc))
+
+		totalDocs += docCounts[db+"."+coll]
+		totalBytes += docBytes[db+"."+coll]
+	}
+
+	summary.Databases = len(dbs)
+	summary.TimeSeries = len(getTimeSeriesPairs(mdDocs))
+
+	if docCounts != nil {
+		summary.Documents = &totalDocs
+	}
+
+	if docBytes != nil {
+		summary.Bytes = &totalBytes
+	}
+
+	return summary
+}
+
+// checkNotEmpty returns an ErrEmpty error if the summary shows no
+// namespaces or, if the archive body was read to count or size documents,
+// no documents.
+func (s Summary) checkNotEmpty() error {
+	switch {
+	case s.Collections+s.Views == 0:
+		return markError(fmt.Errorf("archive contains no namespaces"), ErrEmpty)
+	case s.Documents != nil && *s.Documents == 0,
+		s.Documents == nil && s.Bytes != nil && *s.Bytes == 0:
+		return markError(fmt.Errorf("archive contains no documents"), ErrEmpty)
+	}
+
+	return nil
+}
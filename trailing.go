@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+
+	"github.com/mongodb/mongo-tools/common/archive"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// trailingPreviewSize is how many trailing bytes the report shows.
+const trailingPreviewSize = 16
+
+// TrailingBytes describes data after the end of the archive, such as a
+// second, concatenated archive or garbage from a botched transfer.
+type TrailingBytes struct {
+	// Offset is where the trailing data begins, in the (decompressed)
+	// archive.
+	Offset int64 `bson:"offset"`
+	Length int64 `bson:"length"`
+
+	// Preview is the hex encoding of the data’s first bytes.
+	Preview string `bson:"preview"`
+}
+
+// isNamespaceHeaderNext indicates whether the unread input begins with a
+// namespace header. It consumes nothing.
+func isNamespaceHeaderNext(cr *countingReader, maxSize int) bool {
+	next4, err := cr.Peek(4)
+	if err != nil {
+		return false
+	}
+
+	docLen := int(int32(binary.LittleEndian.Uint32(next4)))
+	if docLen < minDocumentSize || docLen > maxSize {
+		return false
+	}
+
+	raw, err := cr.Peek(docLen)
+	if err != nil || bson.Raw(raw).Validate() != nil {
+		return false
+	}
+
+	header := archive.NamespaceHeader{}
+	if bson.Unmarshal(raw, &header) != nil {
+		return false
+	}
+
+	return header.Database != ""
+}
+
+// readTrailingBytes consumes the rest of the input and describes it.
+func readTrailingBytes(cr *countingReader) (*TrailingBytes, error) {
+	trailing := &TrailingBytes{Offset: cr.BytesRead()}
+
+	// A short peek just means there’s less data than the preview holds.
+	preview, _ := cr.Peek(trailingPreviewSize)
+	trailing.Preview = hex.EncodeToString(preview)
+
+	// Hide the embedded bufio.Reader’s WriteTo, which would bypass the
+	// byte count.
+	length, err := io.Copy(io.Discard, struct{ io.Reader }{cr})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read trailing bytes")
+	}
+
+	trailing.Length = length
+
+	return trailing, nil
+}
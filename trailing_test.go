@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestTrailingBytes(t *testing.T) {
+	dump := makeArchive(
+		t,
+		bson.D{},
+		[]bson.D{makeMetadataDocWithOptions("db", "coll", bson.D{})},
+		[]testBlock{
+			{db: "db", coll: "coll", docs: []bson.D{{{Key: "a", Value: int32(1)}}}},
+			{db: "db", coll: "coll", eof: true},
+		},
+	)
+
+	report, err := getReport(bytes.NewReader(dump), &bytes.Buffer{}, ParseOptions{CountDocuments: true})
+	require.NoError(t, err, "should parse archive")
+	assert.Nil(t, report.TrailingBytes, "should find no trailing bytes")
+
+	// A second archive’s magic bytes aren’t a namespace header.
+	concatenated := slices.Concat(dump, dump)
+
+	warnings := bytes.Buffer{}
+	report, err = getReport(bytes.NewReader(concatenated), &warnings, ParseOptions{CountDocuments: true})
+	require.NoError(t, err, "should parse concatenated archives")
+	assert.Equal(
+		t,
+		&TrailingBytes{
+			Offset:  int64(len(dump)),
+			Length:  int64(len(dump)),
+			Preview: hex.EncodeToString(dump[:trailingPreviewSize]),
+		},
+		report.TrailingBytes,
+		"should report the second archive",
+	)
+	assert.EqualValues(t, len(concatenated), report.BytesRead, "should consume trailing bytes")
+	assert.Equal(t, map[string]int64{"db.coll": 1}, report.DocumentCounts, "should count only the first archive")
+	assert.Contains(t, warnings.String(), "unexpected bytes after its end", "should warn")
+
+	garbage := append(slices.Clone(dump), "oops"...)
+
+	report, err = getReport(bytes.NewReader(garbage), &bytes.Buffer{}, ParseOptions{CountDocuments: true})
+	require.NoError(t, err, "should parse archive with garbage")
+	assert.Equal(
+		t,
+		&TrailingBytes{Offset: int64(len(dump)), Length: 4, Preview: hex.EncodeToString([]byte("oops"))},
+		report.TrailingBytes,
+		"should report short garbage",
+	)
+
+	_, err = getReport(bytes.NewReader(garbage), &bytes.Buffer{}, ParseOptions{CountDocuments: true, Strict: true})
+	assert.ErrorIs(t, err, ErrStrict, "should fail in strict mode")
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// warningCategory groups similar warnings for the end-of-parse summary.
+type warningCategory string
+
+const (
+	warnArchiveVersion     warningCategory = "unknown archive version"
+	warnMetadataParse      warningCategory = "unparseable collection metadata"
+	warnInvalidNamespace   warningCategory = "invalid namespace"
+	warnDuplicateNamespace warningCategory = "duplicate namespace"
+	warnConcurrency        warningCategory = "too many concurrent namespaces"
+	warnTrailingData       warningCategory = "trailing data"
+	warnMixedCompression   warningCategory = "mixed block compression"
+	warnIncomplete         warningCategory = "missing EOF header"
+	warnCappedOverflow     warningCategory = "capped collection overflow"
+	warnAuthData           warningCategory = "users or roles"
+)
+
+// warner reports anomalies in the archive. Normally these are just
+// messages, but in strict mode each one is an error.
+type warner struct {
+	out    io.Writer
+	strict bool
+
+	// categories & counts tally the warnings by category, in order of
+	// each category’s first warning.
+	categories []warningCategory
+	counts     map[warningCategory]int
+}
+
+func newWarner(out io.Writer, strict bool) *warner {
+	return &warner{out: out, strict: strict, counts: map[warningCategory]int{}}
+}
+
+// warn reports an anomaly. It returns an error only in strict mode, in
+// which case the caller should stop and return that error.
+func (w *warner) warn(category warningCategory, format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+
+	w.tally(category)
+
+	if w.strict {
+		return markError(fmt.Errorf("%s", msg), ErrStrict)
+	}
+
+	_, _ = fmt.Fprintln(w.out, msg)
+
+	return nil
+}
+
+// notice reports something noteworthy but normal, like the presence of
+// users. Unlike warn, it never fails, even in strict mode.
+func (w *warner) notice(category warningCategory, format string, args ...any) {
+	w.tally(category)
+
+	_, _ = fmt.Fprintf(w.out, format+"\n", args...)
+}
+
+func (w *warner) tally(category warningCategory) {
+	if w.counts[category] == 0 {
+		w.categories = append(w.categories, category)
+	}
+	w.counts[category]++
+}
+
+// writeSummary writes the number of warnings in each category, if there
+// were any, to out. A nil out writes nothing.
+func (w *warner) writeSummary(out io.Writer) {
+	if out == nil || len(w.categories) == 0 {
+		return
+	}
+
+	_, _ = fmt.Fprintln(out, "warning summary:")
+
+	for _, category := range w.categories {
+		_, _ = fmt.Fprintf(out, "  %s: %d\n", category, w.counts[category])
+	}
+}
+
+// These are the --warnings-to destinations. The file destination is
+// warningsToFilePrefix followed by a path.
+const (
+	warningsToStdout     = "stdout"
+	warningsToStderr     = "stderr"
+	warningsToFilePrefix = "file:"
+)
+
+// warningsFileKey is the root command’s Metadata key for the file that
+// --warnings-to names, if any.
+const warningsFileKey = "warningsFile"
+
+func validateWarningsTo(dest string) error {
+	if dest == warningsToStdout || dest == warningsToStderr {
+		return nil
+	}
+
+	if path, ok := strings.CutPrefix(dest, warningsToFilePrefix); ok && path != "" {
+		return nil
+	}
+
+	return fmt.Errorf("warnings destination must be %#q, %#q, or %#q followed by a path, not %#q", warningsToStdout, warningsToStderr, warningsToFilePrefix, dest)
+}
+
+// openWarningsFile opens, for appending, the file that --warnings-to
+// names, if any. closeWarningsFile closes it.
+func openWarningsFile(ctx context.Context, cmd *cli.Command) (context.Context, error) {
+	path, ok := strings.CutPrefix(cmd.String("warnings-to"), warningsToFilePrefix)
+	if !ok || cmd.Bool("quiet") {
+		return ctx, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return ctx, errors.Wrapf(err, "failed to open warnings file %#q", path)
+	}
+
+	if cmd.Metadata == nil {
+		cmd.Metadata = map[string]any{}
+	}
+	cmd.Metadata[warningsFileKey] = file
+
+	return ctx, nil
+}
+
+func closeWarningsFile(_ context.Context, cmd *cli.Command) error {
+	file, ok := cmd.Metadata[warningsFileKey].(*os.File)
+	if !ok {
+		return nil
+	}
+
+	delete(cmd.Metadata, warningsFileKey)
+
+	return errors.Wrap(file.Close(), "failed to close warnings file")
+}
+
+// getWarningsOut returns where warnings should go, per --quiet and
+// --warnings-to.
+func getWarningsOut(cmd *cli.Command) io.Writer {
+	if cmd.Bool("quiet") {
+		return io.Discard
+	}
+
+	if file, ok := cmd.Root().Metadata[warningsFileKey].(*os.File); ok {
+		return file
+	}
+
+	if cmd.String("warnings-to") == warningsToStdout {
+		return os.Stdout
+	}
+
+	return os.Stderr
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestWarner(t *testing.T) {
+	buf := bytes.Buffer{}
+
+	require.NoError(t, newWarner(&buf, false).warn(warnTrailingData, "odd %s", "thing"), "lenient warning should not fail")
+	assert.Equal(t, "odd thing\n", buf.String(), "should write warning with newline")
+
+	buf.Reset()
+	err := newWarner(&buf, true).warn(warnTrailingData, "odd %s", "thing")
+	assert.ErrorIs(t, err, ErrStrict, "strict warning should fail")
+	assert.EqualError(t, err, "odd thing", "error should carry message")
+	assert.Empty(t, buf.String(), "strict warning should not print")
+}
+
+func TestStrict(t *testing.T) {
+	duplicate := makeArchive(
+		t,
+		bson.D{},
+		[]bson.D{makeMetadataDoc("db", "a"), makeMetadataDoc("db", "a")},
+		nil,
+	)
+
+	_, err := getReport(bytes.NewReader(duplicate), io.Discard, ParseOptions{})
+	assert.NoError(t, err, "duplicate namespace should be only a warning")
+
+	_, err = getReport(bytes.NewReader(duplicate), io.Discard, ParseOptions{Strict: true})
+	assert.ErrorIs(t, err, ErrStrict, "strict mode should reject duplicate namespace")
+
+	unknownVersion := makeArchive(t, bson.D{{Key: "version", Value: "9.9"}}, nil, nil)
+
+	warnings := bytes.Buffer{}
+	_, err = getReport(bytes.NewReader(unknownVersion), &warnings, ParseOptions{})
+	require.NoError(t, err, "unknown version should be only a warning")
+	assert.Equal(t, "archive format version is `9.9`; this tool understands `0.1`\n", warnings.String(), "should warn")
+
+	_, err = getReport(bytes.NewReader(unknownVersion), io.Discard, ParseOptions{Strict: true})
+	assert.ErrorIs(t, err, ErrStrict, "strict mode should reject unknown version")
+
+	badMetadata := makeArchive(
+		t,
+		bson.D{},
+		[]bson.D{append(makeMetadataDoc("db", "a"), bson.E{Key: "metadata", Value: "{not json"})},
+		nil,
+	)
+
+	warnings.Reset()
+	_, err = getReport(bytes.NewReader(badMetadata), &warnings, ParseOptions{})
+	require.NoError(t, err, "unparseable metadata should be only a warning")
+	assert.Contains(t, warnings.String(), "failed to parse collection metadata string", "should warn")
+	assert.True(t, bytes.HasSuffix(warnings.Bytes(), []byte("\n")), "warning should end with a newline")
+
+	_, err = getReport(bytes.NewReader(badMetadata), io.Discard, ParseOptions{Strict: true})
+	assert.ErrorIs(t, err, ErrStrict, "strict mode should reject unparseable metadata")
+	assert.ErrorIs(t, err, ErrMetadataParse, "strict mode should reject unparseable metadata")
+}
+
+func TestWarningSummary(t *testing.T) {
+	dump := makeArchive(
+		t,
+		bson.D{{Key: "version", Value: "9.9"}},
+		[]bson.D{
+			makeMetadataDoc("db", "a"),
+			makeMetadataDoc("db", "a"),
+			makeMetadataDoc("db", "b"),
+			makeMetadataDoc("db", "b"),
+		},
+		nil,
+	)
+
+	summary := bytes.Buffer{}
+	_, err := getReport(bytes.NewReader(dump), io.Discard, ParseOptions{WarningSummaryOut: &summary})
+	require.NoError(t, err, "should parse archive")
+	assert.Equal(
+		t,
+		"warning summary:\n  unknown archive version: 1\n  duplicate namespace: 2\n",
+		summary.String(),
+		"should count warnings by category, in order of first occurrence",
+	)
+
+	summary.Reset()
+	_, err = getReport(bytes.NewReader(dump), io.Discard, ParseOptions{WarningSummaryOut: &summary, Strict: true})
+	require.ErrorIs(t, err, ErrStrict, "strict mode should fail")
+	assert.Equal(t, "warning summary:\n  unknown archive version: 1\n", summary.String(), "should summarize even on failure")
+
+	summary.Reset()
+	_, err = getReport(bytes.NewReader(makeArchive(t, bson.D{}, nil, nil)), io.Discard, ParseOptions{WarningSummaryOut: &summary})
+	require.NoError(t, err, "should parse archive")
+	assert.Empty(t, summary.String(), "should write nothing without warnings")
+}
+
+func TestAuthDataWarnings(t *testing.T) {
+	dump := makeArchive(
+		t,
+		bson.D{},
+		[]bson.D{
+			makeMetadataDoc("admin", "system.users"),
+			makeMetadataDoc("admin", "system.roles"),
+			makeMetadataDoc("app", "$admin.system.users"),
+		},
+		nil,
+	)
+
+	warnings, summary := bytes.Buffer{}, bytes.Buffer{}
+	_, err := getReport(bytes.NewReader(dump), &warnings, ParseOptions{WarningSummaryOut: &summary})
+	require.NoError(t, err, "should parse archive")
+	assert.Equal(
+		t,
+		"archive contains users (admin.system.users)\n"+
+			"archive contains roles (admin.system.roles)\n"+
+			"archive contains users or roles of database `app`\n",
+		warnings.String(),
+		"should warn about users & roles",
+	)
+	assert.Equal(t, "warning summary:\n  users or roles: 3\n", summary.String(), "should summarize auth warnings")
+
+	_, err = getReport(bytes.NewReader(dump), io.Discard, ParseOptions{Strict: true})
+	assert.NoError(t, err, "strict mode should accept users & roles")
+
+	file, err := os.Open("test.dump")
+	require.NoError(t, err, "should open dump file")
+	defer func() { _ = file.Close() }()
+
+	_, err = getReport(file, io.Discard, ParseOptions{Strict: true})
+	assert.NoError(t, err, "strict mode should accept test.dump, which has users & roles")
+}
+
+func TestWarningsTo(t *testing.T) {
+	for _, dest := range []string{"stdout", "stderr", "file:warnings.log"} {
+		assert.NoError(t, validateWarningsTo(dest), "should accept %#q", dest)
+	}
+	for _, dest := range []string{"", "file:", "stdin", "warnings.log"} {
+		assert.Error(t, validateWarningsTo(dest), "should reject %#q", dest)
+	}
+
+	path := filepath.Join(t.TempDir(), "warnings.log")
+	require.NoError(t, os.WriteFile(path, []byte("earlier\n"), 0o644))
+
+	runWarning := func(args ...string) {
+		cmd := &cli.Command{
+			Name: "test",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{Name: "quiet"},
+				&cli.StringFlag{Name: "warnings-to", Value: warningsToStderr, Validator: validateWarningsTo},
+			},
+			Before: openWarningsFile,
+			After:  closeWarningsFile,
+			Action: func(_ context.Context, cmd *cli.Command) error {
+				_, err := io.WriteString(getWarningsOut(cmd), "warning\n")
+				return err
+			},
+		}
+
+		require.NoError(t, cmd.Run(context.Background(), append([]string{"test"}, args...)))
+		assert.NotContains(t, cmd.Metadata, warningsFileKey, "should close file")
+	}
+
+	runWarning("--warnings-to", "file:"+path)
+	runWarning("--warnings-to", "file:"+path, "--quiet")
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "earlier\nwarning\n", string(content), "should append warnings, unless quiet")
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// namespacePredicate tests a collection metadata document.
+type namespacePredicate func(mdDoc bson.D) bool
+
+// These are the properties that --where expressions can test. Boolean
+// properties stand alone (e.g., “capped” or “!capped”); the others take
+// a comparison (e.g., “indexes>3” or “type=view”).
+var (
+	whereBoolProperties = map[string]func(bson.D) bool{
+		"capped": isCapped,
+		"clustered": func(mdDoc bson.D) bool {
+			return getClusteredIndex(getOptions(mdDoc)) != nil
+		},
+	}
+
+	whereStringProperties = map[string]func(bson.D) string{
+		"type": func(mdDoc bson.D) string {
+			collType, _ := bsonutil.FindStringValueByKey("type", &mdDoc)
+			return collType
+		},
+	}
+
+	whereIntProperties = map[string]func(bson.D) int{
+		"indexes": func(mdDoc bson.D) int {
+			return len(getIndexes(mdDoc))
+		},
+	}
+)
+
+var whereComparisons = map[string]func(int) bool{
+	"=":  func(c int) bool { return c == 0 },
+	"!=": func(c int) bool { return c != 0 },
+	"<":  func(c int) bool { return c < 0 },
+	"<=": func(c int) bool { return c <= 0 },
+	">":  func(c int) bool { return c > 0 },
+	">=": func(c int) bool { return c >= 0 },
+}
+
+var (
+	whereBoolPattern       = regexp.MustCompile(`^\s*(!?)\s*(\w+)\s*$`)
+	whereComparisonPattern = regexp.MustCompile(`^\s*(\w+)\s*(!=|<=|>=|=|<|>)\s*(.*?)\s*$`)
+)
+
+func whereProperties() []string {
+	properties := slices.Collect(maps.Keys(whereBoolProperties))
+	properties = slices.AppendSeq(properties, maps.Keys(whereStringProperties))
+	properties = slices.AppendSeq(properties, maps.Keys(whereIntProperties))
+	slices.Sort(properties)
+
+	return properties
+}
+
+// parseWhere parses a --where expression.
+func parseWhere(expr string) (namespacePredicate, error) {
+	if match := whereBoolPattern.FindStringSubmatch(expr); match != nil {
+		negated, property := match[1] == "!", match[2]
+
+		test, ok := whereBoolProperties[property]
+		if !ok {
+			return nil, fmt.Errorf("%#q is not a boolean property (expected one of %v)", property, slices.Sorted(maps.Keys(whereBoolProperties)))
+		}
+
+		return func(mdDoc bson.D) bool {
+			return test(mdDoc) != negated
+		}, nil
+	}
+
+	match := whereComparisonPattern.FindStringSubmatch(expr)
+	if match == nil {
+		return nil, fmt.Errorf("invalid expression %#q (expected e.g. “capped”, “indexes>3”, or “type=view”)", expr)
+	}
+
+	property, comparison, operand := match[1], whereComparisons[match[2]], match[3]
+
+	if get, ok := whereStringProperties[property]; ok {
+		return func(mdDoc bson.D) bool {
+			return comparison(cmp.Compare(get(mdDoc), operand))
+		}, nil
+	}
+
+	if get, ok := whereIntProperties[property]; ok {
+		num, err := strconv.Atoi(operand)
+		if err != nil {
+			return nil, fmt.Errorf("%#q must be compared to an integer, not %#q", property, operand)
+		}
+
+		return func(mdDoc bson.D) bool {
+			return comparison(cmp.Compare(get(mdDoc), num))
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown property %#q (expected one of %v)", property, whereProperties())
+}
+
+// parseWheres parses multiple --where expressions.
+func parseWheres(exprs []string) ([]namespacePredicate, error) {
+	predicates := make([]namespacePredicate, 0, len(exprs))
+
+	for _, expr := range exprs {
+		predicate, err := parseWhere(expr)
+		if err != nil {
+			return nil, err
+		}
+
+		predicates = append(predicates, predicate)
+	}
+
+	return predicates, nil
+}
+
+func validateWheres(exprs []string) error {
+	_, err := parseWheres(exprs)
+	return err
+}
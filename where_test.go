@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseWhere(t *testing.T) {
+	capped := makeMetadataDocWithIndexes(
+		"db",
+		"capped",
+		bson.D{{Key: "capped", Value: true}},
+		bson.A{bson.D{{Key: "key", Value: bson.D{{Key: "_id", Value: 1}}}}},
+	)
+	view := append(makeMetadataDocWithOptions("db", "view", bson.D{}), bson.E{Key: "type", Value: "view"})
+
+	cases := []struct {
+		expr            string
+		capped, viewDoc bool
+	}{
+		{"capped", true, false},
+		{"!capped", false, true},
+		{" ! capped ", false, true},
+		{"type=view", false, true},
+		{"type != view", true, false},
+		{"indexes>0", true, false},
+		{"indexes<=0", false, true},
+		{"indexes=1", true, false},
+	}
+
+	for _, c := range cases {
+		predicate, err := parseWhere(c.expr)
+		require.NoError(t, err, "should parse %#q", c.expr)
+
+		assert.Equal(t, c.capped, predicate(capped), "%#q on capped collection", c.expr)
+		assert.Equal(t, c.viewDoc, predicate(view), "%#q on view", c.expr)
+	}
+
+	for _, expr := range []string{"", "bogus", "!type", "indexes>many", "capped=true", "size>1", "indexes~1"} {
+		_, err := parseWhere(expr)
+		assert.Error(t, err, "should reject %#q", expr)
+	}
+}
+
+func TestWhereFilter(t *testing.T) {
+	dump, err := os.ReadFile("test.dump")
+	require.NoError(t, err, "should read dump file")
+
+	report, err := getReport(
+		bytes.NewReader(dump),
+		io.Discard,
+		ParseOptions{Where: []string{"indexes>1", "!capped"}, CountDocuments: true},
+	)
+	require.NoError(t, err, "should parse dump")
+
+	assert.Len(t, report.CollectionMetadata, 2, "should filter metadata")
+	assert.Equal(
+		t,
+		map[string]int64{"admin.system.users": 4, "admin.system.roles": 4},
+		report.DocumentCounts,
+		"should filter body results to match",
+	)
+
+	_, err = getReport(bytes.NewReader(dump), io.Discard, ParseOptions{Where: []string{"bogus"}})
+	assert.Error(t, err, "should reject invalid expression")
+}